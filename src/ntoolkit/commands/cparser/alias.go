@@ -0,0 +1,282 @@
+package cparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"ntoolkit/commands"
+	"ntoolkit/errors"
+	"ntoolkit/futures"
+)
+
+// maxAliasDepth is how deep aliases can expand into other aliases.
+const maxAliasDepth = 10
+
+// maxAliasCommands is how many commands a single alias can expand to, including nested aliases.
+const maxAliasCommands = 50
+
+// Identity returns the owner of an execution context, eg. the name of a player, or "" if it has none.
+type Identity func(context interface{}) string
+
+// Alias is a user defined shortcut; "kk" -> "kill $1".
+// The expansion can be several commands, separated as they are for a Sequencer; "kill $1; loot $1".
+// $1 to $9 are replaced with the arguments given to the alias, $* with all of them, and $$ with $.
+type Alias struct {
+	Name      string
+	Expansion string
+}
+
+// AliasStore keeps the aliases of each owner. Implement it to persist aliases; it must be
+// safe for concurrent use. Names are given to the store in the normal form of the match policy.
+type AliasStore interface {
+	Aliases(owner string) []Alias
+	Alias(owner string, name string) (Alias, bool)
+	Define(owner string, alias Alias)
+	Remove(owner string, name string) bool
+}
+
+// Aliases expands user defined aliases in command strings before they are matched.
+type Aliases struct {
+	parser    *CommandParser
+	identity  Identity
+	store     AliasStore
+	sequencer *Sequencer
+}
+
+// Aliases adds user defined aliases to the parser, for each owner returned by identity.
+// Aliases are kept in the store, if one is given, or in memory. Call it once per parser;
+// it adds middleware that expands aliases at StageInput.
+func (p *CommandParser) Aliases(identity Identity, store ...AliasStore) *Aliases {
+	rtn := &Aliases{parser: p, identity: identity, sequencer: p.Sequencer()}
+	if len(store) > 0 && store[0] != nil {
+		rtn.store = store[0]
+	} else {
+		rtn.store = NewAliasStore()
+	}
+	p.Use(rtn.middleware)
+	return rtn
+}
+
+// Define adds or replaces an alias for the owner of the context.
+// It fails with ErrBadSyntax if the name isn't a single word, or ErrAliasLoop if the alias
+// would expand to itself through other aliases. An alias can start with its own name, like
+// "look" -> "look north"; that is the command, not the alias.
+func (aliases *Aliases) Define(context interface{}, name string, expansion string) error {
+	owner := aliases.identity(context)
+	if owner == "" {
+		return errors.Fail(ErrBadSyntax{}, nil, "Aliases are not available for this context")
+	}
+	if name == "" || strings.IndexFunc(name, unicode.IsSpace) >= 0 || isQuote(name[0]) {
+		return errors.Fail(ErrBadSyntax{}, nil, fmt.Sprintf("Invalid alias name: %q", name))
+	}
+	if strings.TrimSpace(expansion) == "" {
+		return errors.Fail(ErrBadSyntax{}, nil, fmt.Sprintf("Alias %s has no expansion", name))
+	}
+	if _, err := aliases.sequencer.Split(expansion); err != nil {
+		return err
+	}
+	candidate := &Alias{Name: aliases.normal(name), Expansion: expansion}
+	if _, err := aliases.expand(owner, candidate.Name, candidate, make([]string, 0), make([]string, 0)); err != nil {
+		return err
+	}
+	aliases.store.Define(owner, *candidate)
+	return nil
+}
+
+// Remove removes an alias for the owner of the context, and returns false if it wasn't defined.
+func (aliases *Aliases) Remove(context interface{}, name string) bool {
+	owner := aliases.identity(context)
+	if owner == "" {
+		return false
+	}
+	return aliases.store.Remove(owner, aliases.normal(name))
+}
+
+// List returns the aliases for the owner of the context, sorted by name.
+func (aliases *Aliases) List(context interface{}) []Alias {
+	owner := aliases.identity(context)
+	if owner == "" {
+		return []Alias{}
+	}
+	rtn := aliases.store.Aliases(owner)
+	sort.Slice(rtn, func(i, j int) bool {
+		return rtn[i].Name < rtn[j].Name
+	})
+	return rtn
+}
+
+// Expand returns the commands a command string expands to for the owner of the context.
+// A command string that doesn't start with an alias expands to itself.
+func (aliases *Aliases) Expand(command string, context interface{}) ([]string, error) {
+	owner := aliases.identity(context)
+	if owner == "" {
+		return []string{command}, nil
+	}
+	return aliases.expand(owner, command, nil, make([]string, 0), make([]string, 0))
+}
+
+// expand appends the commands a command string expands to onto rtn; path is the aliases
+// that are already being expanded. If candidate is set, it is used instead of the stored
+// alias with the same name.
+func (aliases *Aliases) expand(owner string, command string, candidate *Alias, path []string, rtn []string) ([]string, error) {
+	tokens, located, err := aliases.parser.tokenize(command)
+	if err != nil {
+		// Leave it to the parser to reject
+		return append(rtn, command), nil
+	}
+	input := &Input{Raw: command, Tokens: tokens, spans: located}
+	spans := input.tokens()
+	if len(spans) == 0 || input.quoted(spans[0]) {
+		return append(rtn, command), nil
+	}
+	name := aliases.normal(spans[0].Value)
+	alias, ok := aliases.store.Alias(owner, name)
+	if candidate != nil && candidate.Name == name {
+		alias, ok = *candidate, true
+	}
+	if !ok || (len(path) > 0 && path[len(path)-1] == name) {
+		// An alias that starts with its own name, like "look" -> "look north", is the command
+		return append(rtn, command), nil
+	}
+	path = append(path, name)
+	for i := 0; i < len(path)-1; i++ {
+		if path[i] == name {
+			return nil, errors.Fail(ErrAliasLoop{}, nil, fmt.Sprintf("Alias %s expands to itself: %s", name, strings.Join(path, " -> ")))
+		}
+	}
+	if len(path) > maxAliasDepth {
+		return nil, errors.Fail(ErrAliasLoop{}, nil, fmt.Sprintf("Alias %s is nested too deeply: %s", path[0], strings.Join(path, " -> ")))
+	}
+	args := make([]string, 0, len(spans)-1)
+	for i := 1; i < len(spans); i++ {
+		args = append(args, input.span(spans, i, i+1))
+	}
+	parts, err := aliases.sequencer.Split(alias.Expansion)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		rtn, err = aliases.expand(owner, substitute(part, args, input.span(spans, 1, len(spans))), candidate, path, rtn)
+		if err != nil {
+			return nil, err
+		}
+		if len(rtn) > maxAliasCommands {
+			return nil, errors.Fail(ErrAliasLoop{}, nil, fmt.Sprintf("Alias %s expands to too many commands", path[0]))
+		}
+	}
+	return rtn, nil
+}
+
+// middleware replaces an alias with its expansion, and executes each command of a macro in turn.
+func (aliases *Aliases) middleware(next Handler) Handler {
+	return func(call *Call) *DeferredCommand {
+		if call.Stage != StageInput {
+			return next(call)
+		}
+		expanded, err := aliases.Expand(call.Raw, call.Context)
+		if err != nil {
+			return aliases.parser.failed(err)
+		}
+		if len(expanded) == 1 {
+			call.Raw = expanded[0]
+		}
+		if len(expanded) <= 1 {
+			return next(call)
+		}
+		rtn := &DeferredCommand{DeferredValue: &futures.DeferredValue{}}
+		var step func(i int)
+		step = func(i int) {
+			part := *call
+			part.Raw = expanded[i]
+			next(&part).Then(func(cmd commands.Command) {
+				if i+1 == len(expanded) {
+					rtn.Resolve(cmd)
+				} else {
+					step(i + 1)
+				}
+			}, rtn.Reject)
+		}
+		step(0)
+		return rtn
+	}
+}
+
+func (aliases *Aliases) normal(name string) string {
+	return aliases.parser.registry().policy.Normal(name)
+}
+
+// substitute replaces $1 to $9, $* and $$ in an alias expansion.
+func substitute(expansion string, args []string, all string) string {
+	buffer := &strings.Builder{}
+	for i := 0; i < len(expansion); i++ {
+		c := expansion[i]
+		if c != '$' || i+1 == len(expansion) {
+			buffer.WriteByte(c)
+			continue
+		}
+		next := expansion[i+1]
+		switch {
+		case next == '$':
+			buffer.WriteByte('$')
+		case next == '*':
+			buffer.WriteString(all)
+		case next >= '1' && next <= '9':
+			if offset := int(next - '1'); offset < len(args) {
+				buffer.WriteString(args[offset])
+			}
+		default:
+			buffer.WriteByte(c)
+			continue
+		}
+		i++
+	}
+	return strings.TrimSpace(buffer.String())
+}
+
+// memoryAliasStore keeps aliases in memory.
+type memoryAliasStore struct {
+	lock    sync.RWMutex
+	aliases map[string]map[string]Alias
+}
+
+// NewAliasStore returns an AliasStore that keeps aliases in memory.
+func NewAliasStore() AliasStore {
+	return &memoryAliasStore{aliases: make(map[string]map[string]Alias)}
+}
+
+func (store *memoryAliasStore) Aliases(owner string) []Alias {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	rtn := make([]Alias, 0, len(store.aliases[owner]))
+	for _, alias := range store.aliases[owner] {
+		rtn = append(rtn, alias)
+	}
+	return rtn
+}
+
+func (store *memoryAliasStore) Alias(owner string, name string) (Alias, bool) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	alias, ok := store.aliases[owner][name]
+	return alias, ok
+}
+
+func (store *memoryAliasStore) Define(owner string, alias Alias) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.aliases[owner] == nil {
+		store.aliases[owner] = make(map[string]Alias)
+	}
+	store.aliases[owner][alias.Name] = alias
+}
+
+func (store *memoryAliasStore) Remove(owner string, name string) bool {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	_, ok := store.aliases[owner][name]
+	delete(store.aliases[owner], name)
+	return ok
+}
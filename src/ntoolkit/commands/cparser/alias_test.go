@@ -0,0 +1,169 @@
+package cparser_test
+
+import (
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+type aliasPlayer struct {
+	name string
+}
+
+func aliasIdentity(context interface{}) string {
+	if player, ok := context.(*aliasPlayer); ok {
+		return player.name
+	}
+	return ""
+}
+
+func TestAliasExpand(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		aliases := p.Aliases(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		T.Assert(aliases.Define(bob, "n", "go north") == nil)
+		T.Assert(aliases.Define(bob, "pp", "put $1 on $2") == nil)
+		T.Assert(aliases.Define(bob, "say", "look $*") == nil)
+		T.Assert(aliases.Define(bob, "cost", "look $$5 $3") == nil)
+
+		expand := func(command string) string {
+			expanded, err := aliases.Expand(command, bob)
+			T.Assert(err == nil)
+			T.Assert(len(expanded) == 1)
+			return expanded[0]
+		}
+		T.Assert(expand("n") == "go north")
+		T.Assert(expand("pp sword table") == "put sword on table")
+		T.Assert(expand("pp \"long sword\" table") == "put \"long sword\" on table")
+		T.Assert(expand("say hello  there") == "look hello  there")
+		T.Assert(expand("cost") == "look $5")
+		T.Assert(expand("go south") == "go south")
+
+		expanded, err := aliases.Expand("n", &aliasPlayer{name: "alice"})
+		T.Assert(err == nil)
+		T.Assert(expanded[0] == "n")
+	})
+}
+
+func TestAliasExecute(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		aliases := p.Aliases(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		T.Assert(aliases.Define(bob, "g", "go $1") == nil)
+
+		cmd, err := p.Wait("g north", bob)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*GoCommand).Direction == "north")
+
+		_, err = p.Wait("g north", &aliasPlayer{name: "alice"})
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+	})
+}
+
+func TestAliasMacro(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		aliases := p.Aliases(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		T.Assert(aliases.Define(bob, "n", "go north") == nil)
+		T.Assert(aliases.Define(bob, "scout", "n; look $1 then go south") == nil)
+
+		expanded, err := aliases.Expand("scout east", bob)
+		T.Assert(err == nil)
+		T.Assert(len(expanded) == 3)
+		T.Assert(expanded[0] == "go north")
+		T.Assert(expanded[1] == "look east")
+
+		cmd, err := p.Wait("scout east", bob)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*GoCommand).Direction == "south")
+
+		T.Assert(aliases.Define(bob, "broken", "go north; dance") == nil)
+		_, err = p.Wait("broken", bob)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+	})
+}
+
+func TestAliasRecursion(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		aliases := p.Aliases(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		T.Assert(aliases.Define(bob, "look", "look north; look $1") == nil)
+		expanded, err := aliases.Expand("look south", bob)
+		T.Assert(err == nil)
+		T.Assert(len(expanded) == 2)
+		T.Assert(expanded[0] == "look north")
+		T.Assert(expanded[1] == "look south")
+		cmd, err := p.Wait("look south", bob)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*LookCommand).Direction == "south")
+
+		T.Assert(aliases.Define(bob, "a", "b") == nil)
+		T.Assert(aliases.Define(bob, "b", "go north") == nil)
+		T.Assert(errors.Is(aliases.Define(bob, "b", "a"), cparser.ErrAliasLoop{}))
+		T.Assert(errors.Is(aliases.Define(bob, "b", "go; a"), cparser.ErrAliasLoop{}))
+		expanded, err = aliases.Expand("a", bob)
+		T.Assert(err == nil)
+		T.Assert(expanded[0] == "go north")
+
+		T.Assert(aliases.Define(bob, "x", "go north; go north; go north; go north") == nil)
+		T.Assert(aliases.Define(bob, "y", "x; x; x; x") == nil)
+		T.Assert(errors.Is(aliases.Define(bob, "z", "y; y; y; y"), cparser.ErrAliasLoop{}))
+	})
+}
+
+// countingAliasStore counts the aliases defined in it.
+type countingAliasStore struct {
+	cparser.AliasStore
+	defined int
+}
+
+func (store *countingAliasStore) Define(owner string, alias cparser.Alias) {
+	store.defined++
+	store.AliasStore.Define(owner, alias)
+}
+
+func TestAliasLoopNotStored(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		store := &countingAliasStore{AliasStore: cparser.NewAliasStore()}
+		aliases := fixture().Aliases(aliasIdentity, store)
+		bob := &aliasPlayer{name: "bob"}
+		T.Assert(aliases.Define(bob, "a", "b") == nil)
+		T.Assert(aliases.Define(bob, "b", "go north") == nil)
+		T.Assert(store.defined == 2)
+
+		T.Assert(errors.Is(aliases.Define(bob, "b", "a"), cparser.ErrAliasLoop{}))
+		T.Assert(store.defined == 2)
+		alias, ok := store.Alias("bob", "b")
+		T.Assert(ok)
+		T.Assert(alias.Expansion == "go north")
+	})
+}
+
+func TestAliasList(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		store := cparser.NewAliasStore()
+		aliases := p.Aliases(aliasIdentity, store)
+		bob := &aliasPlayer{name: "bob"}
+		T.Assert(aliases.Define(bob, "s", "go south") == nil)
+		T.Assert(aliases.Define(bob, "n", "go north") == nil)
+		T.Assert(errors.Is(aliases.Define(bob, "two words", "go north"), cparser.ErrBadSyntax{}))
+		T.Assert(errors.Is(aliases.Define(nil, "n", "go north"), cparser.ErrBadSyntax{}))
+
+		list := aliases.List(bob)
+		T.Assert(len(list) == 2)
+		T.Assert(list[0].Name == "n" && list[0].Expansion == "go north")
+		T.Assert(len(store.Aliases("bob")) == 2)
+
+		T.Assert(aliases.Remove(bob, "n"))
+		T.Assert(!aliases.Remove(bob, "n"))
+		T.Assert(len(aliases.List(bob)) == 1)
+		T.Assert(len(aliases.List(&aliasPlayer{name: "alice"})) == 0)
+	})
+}
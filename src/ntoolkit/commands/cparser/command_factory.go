@@ -11,3 +11,22 @@ type CommandFactory interface {
 	// errors should only be returned if the token stream is invalid.
 	Parse(tokenList *parser.Tokens, commandContext interface{}) (commands.Command, error)
 }
+
+// InputCommandFactory is a CommandFactory that wants the full Input rather than just
+// the token stream; if a factory implements it, ParseInput is used instead of Parse.
+type InputCommandFactory interface {
+	CommandFactory
+
+	// ParseInput has the same contract as Parse.
+	ParseInput(input *Input) (commands.Command, error)
+}
+
+// RankedCommandFactory is a CommandFactory that can report how specifically it matches
+// an input, so that DispatchSpecific can pick between factories that all match it.
+type RankedCommandFactory interface {
+	CommandFactory
+
+	// Rank returns the specificity of the match, or false if the input doesn't match.
+	// It must not invoke any handlers.
+	Rank(input *Input) (Specificity, bool)
+}
@@ -1,18 +1,59 @@
 package cparser
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"ntoolkit/commands"
 	"ntoolkit/errors"
-	"ntoolkit/parser/tools"
+	"ntoolkit/parser"
 )
 
 // CommandParser is a high level interface for dispatching text commands.
+// It is safe for concurrent use, but factories should be fully built before they are registered.
 type CommandParser struct {
-	Commands    *commands.Commands
-	blockParser *tools.BlockParser
-	factory     []CommandFactory
+	Commands *commands.Commands
+
+	// tokenizer splits command strings into tokens; it is set once, by New.
+	tokenizer Tokenizer
+
+	// The current *registry; replaced as a whole, under lock, when anything changes.
+	current atomic.Value
+	lock    sync.Mutex
+}
+
+// registry is the configuration of a CommandParser. It is never modified once published,
+// so commands can be parsed without any locking while factories are being registered.
+type registry struct {
+	factory    []CommandFactory
+	guards     []*registration
+	types      map[string]TokenType
+	policy     MatchPolicy
+	dispatch   Dispatch
+	providers  map[string]CandidateProvider
+	middleware []Middleware
+	recovery   Recovery
+}
+
+// Option configures a CommandParser when it is created by NewWithOptions.
+type Option func(p *CommandParser)
+
+// WithCommands attaches a commands object to the parser, instead of a new blank one.
+func WithCommands(cmd *commands.Commands) Option {
+	return func(p *CommandParser) {
+		p.Commands = cmd
+	}
+}
+
+// WithTokenizer sets the Tokenizer that splits command strings into tokens, instead of
+// a BlockTokenizer; eg. NewShellTokenizer() for shell style quoting and escapes.
+func WithTokenizer(tokenizer Tokenizer) Option {
+	return func(p *CommandParser) {
+		p.tokenizer = tokenizer
+	}
 }
 
 // New returns a new command cparser with the attached commands object.
@@ -22,53 +63,95 @@ func New(cmd ...*commands.Commands) *CommandParser {
 	if len(cmd) > 0 {
 		commander = cmd[0]
 	}
-	if commander == nil {
-		commander = commands.New()
+	return NewWithOptions(WithCommands(commander))
+}
+
+// NewWithOptions returns a new command cparser, configured by the options.
+// If no commands object is supplied with WithCommands a new blank one is created.
+func NewWithOptions(options ...Option) *CommandParser {
+	rtn := &CommandParser{}
+	for _, option := range options {
+		option(rtn)
+	}
+	if rtn.Commands == nil {
+		rtn.Commands = commands.New()
+	}
+	if rtn.tokenizer == nil {
+		rtn.tokenizer = NewBlockTokenizer()
 	}
-	return &CommandParser{
-		Commands:    commander,
-		blockParser: tools.NewBlockParser(),
-		factory:     make([]CommandFactory, 0)  }
+	rtn.current.Store(&registry{
+		factory:   make([]CommandFactory, 0),
+		guards:    make([]*registration, 0),
+		types:     make(map[string]TokenType),
+		providers: make(map[string]CandidateProvider)})
+	return rtn
+}
+
+// Execute parses a command string and executes the command, if any, with the commands object.
+func (p *CommandParser) Execute(command string, userContext interface{}) *DeferredCommand {
+	return p.ExecuteContext(context.Background(), command, userContext)
 }
 
-func (p *CommandParser) Execute(command string, context interface{}) (promise *DeferredCommand) {
+// ExecuteContext is the same as Execute, but if ctx is cancelled or its deadline passes
+// before the command resolves, the command is rejected with ErrCancelled or ErrTimeout.
+// The ctx is available to factories as Input.Ctx, and to handlers as Params.Context().
+// The command passes through each middleware added with Use. Like any context.Context,
+// ctx must not be nil; Execute uses context.Background().
+func (p *CommandParser) ExecuteContext(ctx context.Context, command string, userContext interface{}) (promise *DeferredCommand) {
+	r := p.registry()
 	defer (func() {
-		r := recover()
-		if r != nil {
-			err := r.(error)
-			p.failed(errors.Fail(ErrCommandFailed{}, err, err.Error()))
+		if value := recover(); value != nil {
+			if r.recovery == Repanic {
+				panic(value)
+			}
+			promise = p.failed(panicked(value))
 		}
 	})()
-	p.blockParser.Parse(command)
-	tokens, err := p.blockParser.Finished()
+	return p.run(r, &Call{Stage: StageInput, Raw: command, Context: userContext, Ctx: ctx})
+}
+
+// Parse finds the factory for a command string and builds the command, without executing it.
+// The error is the same error Execute would reject with; ErrBadSyntax, ErrCommandFailed or ErrNoHandler.
+func (p *CommandParser) Parse(command string, userContext interface{}) (commands.Command, *ParseInfo, error) {
+	return p.parse(context.Background(), command, userContext)
+}
+
+func (p *CommandParser) parse(ctx context.Context, command string, userContext interface{}) (commands.Command, *ParseInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, contextError(err)
+	}
+	tokens, spans, err := p.tokenize(command)
 	if err != nil {
-		return p.failed(errors.Fail(ErrBadSyntax{}, err, "Invalid command string"))
+		return nil, nil, errors.Fail(ErrBadSyntax{}, err, "Invalid command string")
 	}
-	for i := range p.factory {
-		cmd, err := p.factory[i].Parse(tokens, context)
-		if err != nil {
-			return p.failed(errors.Fail(ErrCommandFailed{}, err, "Command syntax error"))
-		}
-		if cmd != nil {
-			rtn := &DeferredCommand{}
-			p.Commands.Execute(cmd).Then(func() {
-				rtn.Resolve(cmd)
-			}, func(err error) {
-				rtn.Reject(errors.Fail(ErrCommandFailed{}, err, "Command failed to execute"))
-			})
-			return rtn
+	r := p.registry()
+	input := &Input{Raw: command, Tokens: tokens, Context: userContext, Ctx: ctx, Policy: &r.policy, spans: spans}
+	cmd, info, err := r.match(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cmd == nil {
+		if suggestions := r.suggest(input); suggestions != nil {
+			return nil, nil, errors.Fail(ErrNoHandler{}, suggestions, fmt.Sprintf("No handler supported the given command. %s", suggestions.Error()))
 		}
+		return nil, nil, errors.Fail(ErrNoHandler{}, nil, "No handler supported the given command")
 	}
-	return p.failed(errors.Fail(ErrNoHandler{}, nil, "No handler supported the given command"))
+	return cmd, info, nil
 }
 
 // Wait for an executed command to resolve and return nil or the error.
-func (p *CommandParser) Wait(command string, context interface{}) (commands.Command, error) {
+func (p *CommandParser) Wait(command string, userContext interface{}) (commands.Command, error) {
+	return p.WaitContext(context.Background(), command, userContext)
+}
+
+// WaitContext is the same as Wait, but gives up with ErrCancelled or ErrTimeout if ctx
+// is cancelled or its deadline passes first.
+func (p *CommandParser) WaitContext(ctx context.Context, command string, userContext interface{}) (commands.Command, error) {
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	var err error
 	var cmd commands.Command
-	p.Execute(command, context).Then(func(c commands.Command) {
+	p.ExecuteContext(ctx, command, userContext).Then(func(c commands.Command) {
 		cmd = c
 		wg.Done()
 	}, func(errRtn error) {
@@ -80,25 +163,209 @@ func (p *CommandParser) Wait(command string, context interface{}) (commands.Comm
 }
 
 // Register a new command factory to handle some kind of input.
-func (p *CommandParser) Register(factory CommandFactory) {
-	p.factory = append(p.factory, factory)
+// Pass Requires() to only use the factory for some execution contexts.
+func (p *CommandParser) Register(factory CommandFactory, options ...RegisterOption) {
+	guard := newRegistration(options)
+	p.update(func(r *registry) {
+		r.factory = append(append(make([]CommandFactory, 0, len(r.factory)+1), r.factory...), factory)
+		r.guards = append(append(make([]*registration, 0, len(r.guards)+1), r.guards...), guard)
+	})
+}
+
+// Unregister removes a command factory, and returns false if it was not registered.
+func (p *CommandParser) Unregister(factory CommandFactory) bool {
+	found := false
+	p.update(func(r *registry) {
+		for i := range r.factory {
+			if r.factory[i] == factory {
+				found = true
+				r.factory = append(append(make([]CommandFactory, 0, len(r.factory)-1), r.factory[:i]...), r.factory[i+1:]...)
+				r.guards = append(append(make([]*registration, 0, len(r.guards)-1), r.guards[:i]...), r.guards[i+1:]...)
+				return
+			}
+		}
+	})
+	return found
 }
 
 // Command returns a new standard command factory; you can use .Word() and .Token()
 // on the returned object, or just pass in args; "go" -> Word() and "[name]" -> Token().
+// Prefix either form with '?' to make it optional; "?quietly" or "?[target]".
+// A token ending in "..." is greedy and takes the rest of the input; "[message...]".
+// A token can have a type; "[count:int]", "[dir:enum(north,south)]" -> Token().As().
+// Words separated by '|' are alternatives; "on|onto|upon" -> Words().
+// Flags are in brackets, with their dashes; "[-q|--quiet]" -> Flag(), "[-c|--count=count:int]" -> Option(),
+// and "[--tag=tag]..." -> Repeated().
+// Command never panics; a problem with the syntax, like an unknown token type, is reported
+// by Lint and Validate, and a command that matches the factory fails with ErrInvalidFactory
+// as its inner error.
 func (p *CommandParser) Command(words ...string) *StandardCommandFactory {
 	factory := newStandardCommandFactory()
 	for i := range words {
 		word := words[i]
+		optional := false
+		if len(word) > 1 && word[0] == '?' {
+			optional = true
+			word = word[1:]
+		}
+		if strings.HasPrefix(word, "[-") {
+			// Flags are always optional
+			p.commandFlag(factory, word)
+			continue
+		}
 		if len(word) > 2 && word[0] == '[' && word[len(word)-1] == ']' {
-			factory.Token(word[1:len(word)-1])
+			p.commandToken(factory, word[1:len(word)-1])
+		} else if strings.Contains(word, "|") {
+			factory.Words(strings.Split(word, "|")...)
 		} else {
 			factory.Word(word)
 		}
+		if optional {
+			factory.Optional()
+		}
 	}
 	return factory
 }
 
+// SetPolicy sets the match policy used to compare words, for every factory that
+// doesn't have its own. The default is ExactMatch.
+func (p *CommandParser) SetPolicy(policy MatchPolicy) {
+	p.update(func(r *registry) {
+		r.policy = policy
+	})
+}
+
+// factoryPolicy returns the match policy used by a standard factory.
+func (r *registry) factoryPolicy(factory *StandardCommandFactory) *MatchPolicy {
+	if factory.policy != nil {
+		return factory.policy
+	}
+	return &r.policy
+}
+
+// RegisterType adds a custom token type that can be used in Command() as "[name:type]".
+func (p *CommandParser) RegisterType(kind TokenType) {
+	p.update(func(r *registry) {
+		types := make(map[string]TokenType, len(r.types)+1)
+		for name, existing := range r.types {
+			types[name] = existing
+		}
+		types[kind.Name()] = kind
+		r.types = types
+	})
+}
+
+// commandToken adds a token in the form "name", "name..." or "name:type" to a factory.
+func (p *CommandParser) commandToken(factory *StandardCommandFactory, token string) {
+	greedy := strings.HasSuffix(token, "...")
+	if greedy {
+		token = token[:len(token)-3]
+	}
+	name := token
+	spec := ""
+	if split := strings.Index(token, ":"); split >= 0 {
+		name = token[:split]
+		spec = token[split+1:]
+	}
+	factory.Token(name)
+	if spec != "" {
+		kind, ok := p.tokenType(spec)
+		if !ok {
+			factory.fail(fmt.Sprintf("unknown token type '%s'", spec))
+		} else {
+			factory.As(kind)
+		}
+	}
+	if greedy {
+		factory.Greedy()
+	}
+}
+
+// commandFlag adds a flag in the form "[-q|--quiet]" or "[-c|--count=name:type]" to a factory;
+// a trailing "..." makes it repeated. Boolean flags are named after their first long spelling.
+func (p *CommandParser) commandFlag(factory *StandardCommandFactory, flag string) {
+	repeated := strings.HasSuffix(flag, "...")
+	if repeated {
+		flag = flag[:len(flag)-3]
+	}
+	flag = strings.TrimSuffix(strings.TrimPrefix(flag, "["), "]")
+	value := ""
+	if split := strings.Index(flag, "="); split >= 0 {
+		value = flag[split+1:]
+		flag = flag[:split]
+	}
+	spellings := strings.Split(flag, "|")
+	if value == "" {
+		name := strings.TrimLeft(spellings[0], "-")
+		for i := range spellings {
+			if strings.HasPrefix(spellings[i], "--") {
+				name = spellings[i][2:]
+				break
+			}
+		}
+		factory.Flag(name, spellings...)
+	} else {
+		name := value
+		spec := ""
+		if split := strings.Index(value, ":"); split >= 0 {
+			name = value[:split]
+			spec = value[split+1:]
+		}
+		factory.Option(name, spellings...)
+		if spec != "" {
+			kind, ok := p.tokenType(spec)
+			if !ok {
+				factory.fail(fmt.Sprintf("unknown token type '%s'", spec))
+			} else {
+				factory.As(kind)
+			}
+		}
+	}
+	if repeated {
+		factory.Repeated()
+	}
+}
+
+// tokenType returns the registered or built in type for a spec.
+func (p *CommandParser) tokenType(spec string) (TokenType, bool) {
+	if kind, ok := p.registry().types[spec]; ok {
+		return kind, true
+	}
+	return tokenType(spec)
+}
+
+// tokenize converts a command string into a token stream, with the spans of the tokens
+// if the tokenizer knows them, or nil.
+func (p *CommandParser) tokenize(command string) (*parser.Tokens, []Span, error) {
+	if spanTokenizer, ok := p.tokenizer.(SpanTokenizer); ok {
+		return spanTokenizer.TokenizeSpans(command)
+	}
+	tokens, err := p.tokenizer.Tokenize(command)
+	return tokens, nil, err
+}
+
+// registry returns the current configuration of the parser.
+func (p *CommandParser) registry() *registry {
+	return p.current.Load().(*registry)
+}
+
+// update changes a copy of the current configuration and then publishes it.
+func (p *CommandParser) update(change func(r *registry)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	next := *p.registry()
+	change(&next)
+	p.current.Store(&next)
+}
+
+// contextError converts the error of a finished context.Context into ErrTimeout or ErrCancelled.
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return errors.Fail(ErrTimeout{}, err, "Command timed out")
+	}
+	return errors.Fail(ErrCancelled{}, err, "Command was cancelled")
+}
+
 func (p *CommandParser) failed(err error) *DeferredCommand {
 	rtn := &DeferredCommand{}
 	rtn.Reject(err)
@@ -7,6 +7,7 @@ import (
 	"ntoolkit/commands"
 	"ntoolkit/commands/cparser"
 	"ntoolkit/errors"
+	"ntoolkit/parser"
 )
 
 func fixture() *cparser.CommandParser {
@@ -246,4 +247,49 @@ func TestMultipleCommandSyntax(T *testing.T) {
 			T.Assert(errors.Is(inner, ErrInvalidDragon{}))
 		})
 	})
-}
\ No newline at end of file
+}
+
+// PolicyLookCommandFactory is LookCommandFactory, but compares words under the policy of the input.
+type PolicyLookCommandFactory struct {
+}
+
+func (factory *PolicyLookCommandFactory) Parse(tokenList *parser.Tokens, context interface{}) (commands.Command, error) {
+	return factory.ParseInput(&cparser.Input{Tokens: tokenList, Context: context})
+}
+
+func (factory *PolicyLookCommandFactory) ParseInput(input *cparser.Input) (commands.Command, error) {
+	if input.Tokens.Front != nil && input.Policy.MatchToken(input.Tokens.Front, "look") {
+		if direction := input.Tokens.Front.Next; direction != nil {
+			return &LookCommand{Direction: direction.CollectRaw(" ")}, nil
+		}
+		return nil, errors.Fail(cparser.ErrBadSyntax{}, nil, "Invalid look syntax; try 'look DIRECTION'")
+	}
+	return nil, nil
+}
+
+func TestMatchPolicyCustomFactory(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.Register(&PolicyLookCommandFactory{})
+		p.Commands.Register(&LookCommandHandler{})
+
+		_, err := p.Wait("LOOK north", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+
+		p.SetPolicy(cparser.LooseMatch)
+		cmd, err := p.Wait("LOOK North", nil)
+		T.Assert(err == nil)
+		lcmd, ok := cmd.(*LookCommand)
+		T.Assert(ok)
+		T.Assert(lcmd.Direction == "North")
+
+		// Factories that only implement Parse compare words exactly
+		p = fixture()
+		p.SetPolicy(cparser.LooseMatch)
+		_, err = p.Wait("LOOK North", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+		cmd, err = p.Wait("look North", nil)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*LookCommand).Direction == "North")
+	})
+}
@@ -0,0 +1,196 @@
+package cparser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Completion is a possible completion of a partially typed command string.
+type Completion struct {
+	// Text is the completed word or token value, quoted if it contains spaces.
+	Text string
+
+	// Start and End are the byte offsets in the partial command string that Text replaces.
+	Start int
+	End   int
+
+	// Token is the name of the token the value is for, or "" for a literal word.
+	Token string
+}
+
+// CandidateProvider returns the possible values of a token for tab completion, given the
+// execution context and what has been typed of the token so far.
+type CandidateProvider func(context interface{}, prefix string) []string
+
+// Candidates sets the provider of completion values for every token with the given name,
+// unless the factory has its own provider for that token.
+func (p *CommandParser) Candidates(token string, provider CandidateProvider) {
+	p.update(func(r *registry) {
+		providers := make(map[string]CandidateProvider, len(r.providers)+1)
+		for name, existing := range r.providers {
+			providers[name] = existing
+		}
+		providers[token] = provider
+		r.providers = providers
+	})
+}
+
+// Complete returns the words and token values that could come next in a partially typed
+// command string; if it doesn't end with a space, the last word is completed.
+// Token values come from the CandidateProvider for the token, or the values of enum tokens.
+func (p *CommandParser) Complete(partial string, context interface{}) []Completion {
+	tokens, spans, err := p.tokenize(partial)
+	if err != nil {
+		// Probably an unfinished quoted block; close it and try again
+		tokens, spans, err = p.tokenize(partial + "\"")
+		if err != nil {
+			return nil
+		}
+		for i := range spans {
+			if spans[i].End > len(partial) {
+				spans[i].End = len(partial)
+			}
+		}
+	}
+	r := p.registry()
+	input := &Input{Raw: partial, Tokens: tokens, Context: context, Policy: &r.policy, spans: spans}
+	complete := input.tokens()
+	prefix := ""
+	start := len(partial)
+	if len(complete) > 0 && !strings.HasSuffix(partial, "\"") && !endsWithSpace(partial) {
+		last := complete[len(complete)-1]
+		complete = complete[:len(complete)-1]
+		prefix = last.Value
+		start = len(partial) - len(prefix)
+		if last.Start >= 0 {
+			start = last.Start
+		}
+	}
+
+	rtn := make([]Completion, 0)
+	seen := make(map[string]bool)
+	add := func(policy *MatchPolicy, values []string, token string) {
+		for _, value := range values {
+			if !strings.HasPrefix(policy.Normal(value), policy.Normal(prefix)) {
+				continue
+			}
+			if strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+				value = "\"" + value + "\""
+			}
+			if !seen[value] {
+				seen[value] = true
+				rtn = append(rtn, Completion{Text: value, Start: start, End: len(partial), Token: token})
+			}
+		}
+	}
+	for i := range r.factory {
+		factory, ok := r.factory[i].(*StandardCommandFactory)
+		if !ok {
+			continue
+		}
+		if allowed, _ := r.allows(i, context); !allowed {
+			continue
+		}
+		policy := r.factoryPolicy(factory)
+		state := &standardCommandState{input: input, tokens: complete, params: newParams(), policy: policy}
+		if len(factory.flags) > 0 {
+			flags := factory.splitFlags(input, complete)
+			state.tokens = flags.positional
+			if flags.pending != nil {
+				// The last token is an option waiting for its value
+				if enum, ok := flags.pending.Kind.(*enumTokenType); ok {
+					add(policy, enum.values, flags.pending.Name)
+				}
+				continue
+			}
+			if strings.HasPrefix(prefix, "-") {
+				if factory.started(state) {
+					add(nil, factory.spellings(), "")
+				}
+				continue
+			}
+		}
+		factory.expect(state, 0, 0, func(item *standardCommandWord) {
+			token := ""
+			if item.Type == standardCommandTypeToken {
+				token = item.Name
+			}
+			add(policy, r.candidates(factory, item, context, prefix), token)
+		})
+	}
+	return rtn
+}
+
+// started returns true if the tokens start with the first word of the factory, so its flags can be completed.
+func (factory *StandardCommandFactory) started(state *standardCommandState) bool {
+	return len(state.tokens) > 0 && len(factory.items) > 0 && factory.items[0].Type == standardCommandTypeWord && factory.items[0].matches(state.policy, state.tokens[0].Value)
+}
+
+// candidates returns every possible value of an item.
+func (r *registry) candidates(factory *StandardCommandFactory, item *standardCommandWord, context interface{}, prefix string) []string {
+	if item.Type == standardCommandTypeWord {
+		if len(item.Alternatives) > 0 {
+			return item.Alternatives
+		}
+		return []string{item.Name}
+	}
+	if provider, ok := factory.providers[item.Name]; ok {
+		return provider(context, prefix)
+	}
+	if provider, ok := r.providers[item.Name]; ok {
+		return provider(context, prefix)
+	}
+	if enum, ok := item.Kind.(*enumTokenType); ok {
+		return enum.values
+	}
+	return nil
+}
+
+// expect calls found with every item that could follow the tokens, for every way the
+// tokens can match the items from offset onwards.
+func (factory *StandardCommandFactory) expect(state *standardCommandState, offset int, marker int, found func(item *standardCommandWord)) {
+	if marker == len(state.tokens) {
+		for ; offset < len(factory.items); offset++ {
+			found(&factory.items[offset])
+			if !factory.items[offset].Optional {
+				break
+			}
+		}
+		return
+	}
+	if offset == len(factory.items) {
+		return
+	}
+	item := &factory.items[offset]
+	raw := state.tokens[marker].Value
+	if item.Type == standardCommandTypeWord && item.matches(state.policy, raw) {
+		factory.expect(state, offset+1, marker+1, found)
+	} else if item.Type == standardCommandTypeToken && item.Greedy {
+		for end := len(state.tokens); end > marker; end-- {
+			if item.valid(state.policy, state.input.span(state.tokens, marker, end)) {
+				if end == len(state.tokens) {
+					found(item)
+				}
+				factory.expect(state, offset+1, end, found)
+			}
+		}
+	} else if item.Type == standardCommandTypeToken && item.valid(state.policy, raw) {
+		factory.expect(state, offset+1, marker+1, found)
+	}
+	if item.Optional {
+		factory.expect(state, offset+1, marker, found)
+	}
+}
+
+// valid returns true if raw is a valid value for this token under the policy.
+func (item *standardCommandWord) valid(policy *MatchPolicy, raw string) bool {
+	if item.Kind == nil {
+		return true
+	}
+	_, err := convert(item.Kind, policy, raw)
+	return err == nil
+}
+
+func endsWithSpace(value string) bool {
+	return value == "" || strings.LastIndexFunc(value, unicode.IsSpace) == len(value)-1
+}
@@ -0,0 +1,76 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+)
+
+type completeInventory struct {
+	items []string
+}
+
+func completeFixture() *cparser.CommandParser {
+	p := cparser.New()
+	p.Register(p.Command("put", "[item]", "on|onto", "[target]").With(lintHandler).
+		Candidates("target", func(context interface{}, prefix string) []string {
+			return []string{"table", "shelf"}
+		}))
+	p.Register(p.Command("put", "[item]", "in", "[container]").With(lintHandler))
+	p.Register(p.Command("go", "[dir:enum(north,south,east)]", "?quickly").With(lintHandler))
+	p.Register(p.Command("look").With(lintHandler))
+	p.Candidates("item", func(context interface{}, prefix string) []string {
+		return context.(*completeInventory).items
+	})
+	return p
+}
+
+func completionText(completions []cparser.Completion) string {
+	text := make([]string, len(completions))
+	for i := range completions {
+		text[i] = completions[i].Text
+	}
+	return strings.Join(text, ",")
+}
+
+func TestCompleteWords(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := completeFixture()
+		inventory := &completeInventory{}
+
+		T.Assert(completionText(p.Complete("", inventory)) == "put,go,look")
+		T.Assert(completionText(p.Complete("p", inventory)) == "put")
+		T.Assert(completionText(p.Complete("put sword ", inventory)) == "on,onto,in")
+		T.Assert(completionText(p.Complete("put sword o", inventory)) == "on,onto")
+		T.Assert(completionText(p.Complete("look ", inventory)) == "")
+
+		completions := p.Complete("put sword o", inventory)
+		T.Assert(completions[0].Start == 10)
+		T.Assert(completions[0].End == 11)
+		T.Assert(completions[0].Token == "")
+	})
+}
+
+func TestCompleteTokens(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := completeFixture()
+		inventory := &completeInventory{items: []string{"sword", "magic hammer", "shield"}}
+
+		T.Assert(completionText(p.Complete("put s", inventory)) == "sword,shield")
+		T.Assert(completionText(p.Complete("put ", inventory)) == "sword,\"magic hammer\",shield")
+		T.Assert(completionText(p.Complete("put \"magic h", inventory)) == "\"magic hammer\"")
+		T.Assert(completionText(p.Complete("put sword on t", inventory)) == "table")
+		T.Assert(completionText(p.Complete("go ", inventory)) == "north,south,east")
+		T.Assert(completionText(p.Complete("go north ", inventory)) == "quickly")
+
+		completions := p.Complete("put s", inventory)
+		T.Assert(completions[0].Token == "item")
+		T.Assert(completions[0].Start == 4)
+		T.Assert(completions[0].End == 5)
+
+		completions = p.Complete("put \"magic h", inventory)
+		T.Assert(completions[0].Start == 4)
+	})
+}
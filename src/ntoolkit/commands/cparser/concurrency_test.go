@@ -0,0 +1,87 @@
+package cparser_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+)
+
+// Run with go test -race to check for data races.
+func TestConcurrentExecute(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		failures := make(chan string, 100)
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					direction := fmt.Sprintf("north%d-%d", id, j)
+					cmd, err := p.Wait(fmt.Sprintf("go \"%s\"", direction), nil)
+					if err != nil {
+						failures <- err.Error()
+						return
+					}
+					if cmd.(*GoCommand).Direction != direction {
+						failures <- fmt.Sprintf("expected %s, got %s", direction, cmd.(*GoCommand).Direction)
+						return
+					}
+					cmd, err = p.Wait(fmt.Sprintf("put item%d on table%d", id, j), id)
+					if err != nil {
+						failures <- err.Error()
+						return
+					}
+					pcmd := cmd.(*PutCommand)
+					if pcmd.Item != fmt.Sprintf("item%d", id) || pcmd.Target != fmt.Sprintf("table%d", j) {
+						failures <- fmt.Sprintf("bad put command: %s on %s", pcmd.Item, pcmd.Target)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(failures)
+		messages := make([]string, 0)
+		for failure := range failures {
+			messages = append(messages, failure)
+		}
+		T.Assert(len(messages) == 0)
+	})
+}
+
+func TestConcurrentRegister(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					factory := p.Command(fmt.Sprintf("cmd%d", id), "[value]").With(func(params map[string]string, context interface{}) (commands.Command, error) {
+						return &GoCommand{Direction: params["value"]}, nil
+					})
+					p.Register(factory)
+					p.SetPolicy(cparser.LooseMatch)
+					p.Unregister(factory)
+				}
+			}(i)
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					p.Wait("look north", nil)
+					p.Complete("pu", nil)
+					p.Lint()
+					p.Help().Overview()
+				}
+			}(i)
+		}
+		wg.Wait()
+		T.Assert(p.Validate() == nil)
+	})
+}
@@ -0,0 +1,114 @@
+package cparser_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+	"ntoolkit/events"
+	"ntoolkit/futures"
+)
+
+type StallCommand struct {
+	eventHandler *events.EventHandler
+}
+
+func (cmd *StallCommand) EventHandler() *events.EventHandler {
+	if cmd.eventHandler == nil {
+		cmd.eventHandler = events.New()
+	}
+	return cmd.eventHandler
+}
+
+// StallCommandHandler never resolves the commands it is given.
+type StallCommandHandler struct {
+}
+
+func (handler *StallCommandHandler) Handles() reflect.Type {
+	return reflect.TypeOf(&StallCommand{})
+}
+
+func (handler *StallCommandHandler) Execute(command interface{}) *futures.Deferred {
+	return &futures.Deferred{}
+}
+
+func stallFixture() *cparser.CommandParser {
+	p := fixture()
+	p.Commands.Register(&StallCommandHandler{})
+	p.Register(p.Command("stall").Handle(func(params *cparser.Params, context interface{}) (commands.Command, error) {
+		return &StallCommand{}, nil
+	}))
+	return p
+}
+
+func TestWaitContextTimeout(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := stallFixture()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := p.WaitContext(ctx, "stall", nil)
+		T.Assert(errors.Is(err, cparser.ErrTimeout{}))
+		inner, ok := errors.Inner(err)
+		T.Assert(ok && inner == context.DeadlineExceeded)
+	})
+}
+
+func TestWaitContextCancel(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := stallFixture()
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		_, err := p.WaitContext(ctx, "stall", nil)
+		T.Assert(errors.Is(err, cparser.ErrCancelled{}))
+	})
+}
+
+func TestExecuteContextAlreadyCancelled(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := p.WaitContext(ctx, "go north", nil)
+		T.Assert(errors.Is(err, cparser.ErrCancelled{}))
+	})
+}
+
+func TestWaitContextResolves(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		cmd, err := p.WaitContext(ctx, "go north", nil)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*GoCommand).Direction == "north")
+	})
+}
+
+type contextKey struct{}
+
+func TestParamsContext(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		p.Commands.Register(&StallCommandHandler{})
+		seen := make(chan context.Context, 1)
+		p.Register(p.Command("stall").Handle(func(params *cparser.Params, context interface{}) (commands.Command, error) {
+			seen <- params.Context()
+			return &StallCommand{}, nil
+		}))
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "value"))
+		defer cancel()
+		p.ExecuteContext(ctx, "stall", nil)
+		T.Assert((<-seen).Value(contextKey{}) == "value")
+
+		p.Parse("stall", nil)
+		T.Assert(<-seen == context.Background())
+	})
+}
@@ -0,0 +1,184 @@
+package cparser
+
+import (
+	"fmt"
+	"strings"
+
+	"ntoolkit/commands"
+	"ntoolkit/errors"
+)
+
+// Dispatch is how a CommandParser picks the factory for a command string.
+type Dispatch int
+
+const (
+	// DispatchFirst uses the first registered factory that matches.
+	DispatchFirst Dispatch = iota
+
+	// DispatchSpecific uses the most specific RankedCommandFactory that matches,
+	// and raises ErrAmbiguous if there is a tie. If no ranked factory matches
+	// the factories are tried in registration order, as for DispatchFirst.
+	DispatchSpecific
+)
+
+// Specificity is how specifically a factory matched an input.
+// Priority is compared first, then Words, Typed and Tokens; higher is more specific.
+type Specificity struct {
+	// Priority is an explicit priority for the factory.
+	Priority int
+
+	// Words is the number of literal words matched.
+	Words int
+
+	// Typed is the number of typed tokens matched.
+	Typed int
+
+	// Tokens is the number of input tokens used by the match; this is lower
+	// for a factory that ignores trailing input.
+	Tokens int
+}
+
+// Compare returns 1 if this is more specific than other, -1 if it is less, or 0 if they are equal.
+func (s Specificity) Compare(other Specificity) int {
+	if s.Priority != other.Priority {
+		return compareInt(s.Priority, other.Priority)
+	}
+	if s.Words != other.Words {
+		return compareInt(s.Words, other.Words)
+	}
+	if s.Typed != other.Typed {
+		return compareInt(s.Typed, other.Typed)
+	}
+	return compareInt(s.Tokens, other.Tokens)
+}
+
+// SetDispatch sets how the factory for a command string is picked. The default is DispatchFirst.
+func (p *CommandParser) SetDispatch(dispatch Dispatch) {
+	p.update(func(r *registry) {
+		r.dispatch = dispatch
+	})
+}
+
+// match returns the command for an input, or nil if no factory matched it.
+func (r *registry) match(input *Input) (commands.Command, *ParseInfo, error) {
+	if r.dispatch == DispatchSpecific {
+		best, err := r.mostSpecific(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		if best >= 0 {
+			return r.parse(input, best)
+		}
+	}
+	for i := range r.factory {
+		if input.Ctx != nil && input.Ctx.Err() != nil {
+			return nil, nil, contextError(input.Ctx.Err())
+		}
+		cmd, info, err := r.parse(input, i)
+		if err != nil || cmd != nil {
+			return cmd, info, err
+		}
+	}
+	return nil, nil, nil
+}
+
+// parse runs the factory at offset i on an input, if its guards allow the input context.
+// A revealed factory that isn't allowed is only ranked, so its handler never runs.
+func (r *registry) parse(input *Input, i int) (commands.Command, *ParseInfo, error) {
+	allowed, revealed := r.allows(i, input.Context)
+	if !allowed {
+		if revealed && ranks(input, r.factory[i]) {
+			return nil, nil, forbidden(r.factory[i])
+		}
+		return nil, nil, nil
+	}
+	cmd, info, err := parse(input, r.factory[i])
+	r.repanic(err)
+	return cmd, info, err
+}
+
+// ranks returns true if a RankedCommandFactory matches an input; other factories never do.
+func ranks(input *Input, factory CommandFactory) bool {
+	ranked, ok := factory.(RankedCommandFactory)
+	if !ok {
+		return false
+	}
+	_, matched := ranked.Rank(input)
+	return matched
+}
+
+// mostSpecific returns the offset of the most specific ranked factory for an input, or -1 if none match.
+func (r *registry) mostSpecific(input *Input) (int, error) {
+	var best Specificity
+	candidates := make([]int, 0)
+	for i := range r.factory {
+		ranked, ok := r.factory[i].(RankedCommandFactory)
+		if !ok {
+			continue
+		}
+		if allowed, revealed := r.allows(i, input.Context); !allowed && !revealed {
+			continue
+		}
+		specificity, ok := ranked.Rank(input)
+		if !ok {
+			continue
+		}
+		compared := specificity.Compare(best)
+		if len(candidates) == 0 || compared > 0 {
+			best = specificity
+			candidates = []int{i}
+		} else if compared == 0 {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) > 1 {
+		syntax := make([]string, len(candidates))
+		for i := range candidates {
+			syntax[i] = describeFactory(r.factory[candidates[i]])
+		}
+		return -1, errors.Fail(ErrAmbiguous{}, nil, fmt.Sprintf("Ambiguous command, could be any of: %s", strings.Join(syntax, ", ")))
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	return -1, nil
+}
+
+// parse runs a single factory on an input.
+func parse(input *Input, factory CommandFactory) (cmd commands.Command, info *ParseInfo, err error) {
+	defer (func() {
+		if value := recover(); value != nil {
+			cmd, info, err = nil, nil, errors.Fail(ErrCommandFailed{}, panicked(value), "Command syntax error")
+		}
+	})()
+	var params *Params
+	if standard, ok := factory.(*StandardCommandFactory); ok {
+		cmd, params, err = standard.parse(input)
+	} else {
+		cmd, err = input.parse(factory)
+	}
+	if err != nil {
+		return nil, nil, errors.Fail(ErrCommandFailed{}, err, "Command syntax error")
+	}
+	if cmd == nil {
+		return nil, nil, nil
+	}
+	return cmd, &ParseInfo{Factory: factory, Syntax: describeFactory(factory), Params: params, Tokens: input.tokens()}, nil
+}
+
+// describeFactory returns the syntax of a factory, if it has one, or its type.
+func describeFactory(factory CommandFactory) string {
+	if stringer, ok := factory.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", factory)
+}
+
+func compareInt(a int, b int) int {
+	if a > b {
+		return 1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
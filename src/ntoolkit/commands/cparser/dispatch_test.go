@@ -0,0 +1,88 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func dispatchFixture(dispatch cparser.Dispatch) (*cparser.CommandParser, *string) {
+	matched := new(string)
+	handler := func(name string) func(params map[string]string, context interface{}) (commands.Command, error) {
+		return func(params map[string]string, context interface{}) (commands.Command, error) {
+			*matched = name
+			return &GoCommand{}, nil
+		}
+	}
+	p := cparser.New()
+	p.SetDispatch(dispatch)
+	p.Register(p.Command().Word("put", true).Strict(false).With(handler("generic")))
+	p.Register(p.Command("put", "[item]", "on", "[target]").With(handler("on")))
+	p.Register(p.Command("put", "[count:int]", "[item]").With(handler("count")))
+	p.Register(p.Command("put", "[item]", "[target]").With(handler("any")))
+	p.Commands.Register(&GoCommandHandler{})
+	return p, matched
+}
+
+func TestDispatchFirst(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, matched := dispatchFixture(cparser.DispatchFirst)
+		_, err := p.Wait("put sword on table", nil)
+		T.Assert(err == nil)
+		T.Assert(*matched == "generic")
+	})
+}
+
+func TestDispatchSpecific(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, matched := dispatchFixture(cparser.DispatchSpecific)
+
+		_, err := p.Wait("put sword on table", nil)
+		T.Assert(err == nil)
+		T.Assert(*matched == "on")
+
+		_, err = p.Wait("put 3 swords", nil)
+		T.Assert(err == nil)
+		T.Assert(*matched == "count")
+
+		_, err = p.Wait("put sword table", nil)
+		T.Assert(err == nil)
+		T.Assert(*matched == "any")
+
+		_, err = p.Wait("put", nil)
+		T.Assert(err == nil)
+		T.Assert(*matched == "generic")
+	})
+}
+
+func TestDispatchSpecificPriority(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, matched := dispatchFixture(cparser.DispatchSpecific)
+		p.Register(p.Command("put", "[item...]").Priority(1).With(func(params map[string]string, context interface{}) (commands.Command, error) {
+			*matched = "priority"
+			return &GoCommand{}, nil
+		}))
+
+		_, err := p.Wait("put sword on table", nil)
+		T.Assert(err == nil)
+		T.Assert(*matched == "priority")
+	})
+}
+
+func TestDispatchSpecificAmbiguous(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, _ := dispatchFixture(cparser.DispatchSpecific)
+		p.Register(p.Command("put", "[thing]", "on", "[where]").With(func(params map[string]string, context interface{}) (commands.Command, error) {
+			return &GoCommand{}, nil
+		}))
+
+		_, err := p.Wait("put sword on table", nil)
+		T.Assert(errors.Is(err, cparser.ErrAmbiguous{}))
+		T.Assert(strings.Contains(err.Error(), "put [item] on [target]"))
+		T.Assert(strings.Contains(err.Error(), "put [thing] on [where]"))
+	})
+}
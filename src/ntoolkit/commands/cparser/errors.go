@@ -1,10 +1,151 @@
 package cparser
 
+import (
+	"reflect"
+
+	"ntoolkit/errors"
+)
+
 // ErrNotHandled is raised when a command string didn't match any factory.
 type ErrNoHandler struct{}
 
+func (ErrNoHandler) Error() string { return "no handler supported the command" }
+
 // ErrBadSyntax is raised when a command string fails parsing.
 type ErrBadSyntax struct{}
 
+func (ErrBadSyntax) Error() string { return "invalid command syntax" }
+
 // ErrCommandFailed is raised when a command fails to execute.
-type ErrCommandFailed struct{}
\ No newline at end of file
+type ErrCommandFailed struct{}
+
+func (ErrCommandFailed) Error() string { return "command failed" }
+
+// ErrAmbiguous is raised when DispatchSpecific finds more than one equally specific factory.
+type ErrAmbiguous struct{}
+
+func (ErrAmbiguous) Error() string { return "ambiguous command" }
+
+// ErrInvalidFactory is raised by Validate when registered factories have problems.
+type ErrInvalidFactory struct{}
+
+func (ErrInvalidFactory) Error() string { return "invalid factory" }
+
+// ErrTimeout is raised when the deadline of the context.Context given to ExecuteContext
+// or WaitContext passes before the command resolves.
+type ErrTimeout struct{}
+
+func (ErrTimeout) Error() string { return "command timed out" }
+
+// ErrCancelled is raised when the context.Context given to ExecuteContext or WaitContext
+// is cancelled before the command resolves.
+type ErrCancelled struct{}
+
+func (ErrCancelled) Error() string { return "command was cancelled" }
+
+// ErrForbidden is raised when a command matches a factory registered with Reveal(),
+// but a guard of the factory rejects the execution context.
+type ErrForbidden struct{}
+
+func (ErrForbidden) Error() string { return "command is forbidden" }
+
+// ErrAliasLoop is raised when an alias expands to itself, or to too many commands.
+type ErrAliasLoop struct{}
+
+func (ErrAliasLoop) Error() string { return "alias expands to itself" }
+
+// ErrNoHistory is raised when a command repeats or recalls a command that isn't in the history.
+type ErrNoHistory struct{}
+
+func (ErrNoHistory) Error() string { return "no such command in history" }
+
+// Error is a rejection from cparser as a standard library error chain; see Wrap.
+type Error struct {
+	// Kind is the cparser error type, eg. ErrNoHandler{}, or nil if the error came from elsewhere,
+	// eg. a command handler.
+	Kind error
+
+	Message string
+
+	// The ntoolkit error this was made from, and the next error in the chain.
+	cause error
+	inner error
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Unwrap returns the next error in the chain; for ErrCommandFailed, the error of the handler.
+func (err *Error) Unwrap() error {
+	return err.inner
+}
+
+// Cause returns the ntoolkit error this error was made from, for errors.Is and errors.Inner from ntoolkit.
+func (err *Error) Cause() error {
+	return err.cause
+}
+
+// Is returns true if target is the same kind of error, eg. errors.Is(err, cparser.ErrNoHandler{}),
+// or the same type of ntoolkit error.
+func (err *Error) Is(target error) bool {
+	if err.Kind != nil && reflect.TypeOf(err.Kind) == reflect.TypeOf(target) {
+		return true
+	}
+	return errors.Is(err.cause, target)
+}
+
+// As sets target if it points to the kind of the error, eg. var e cparser.ErrNoHandler; errors.As(err, &e)
+func (err *Error) As(target interface{}) bool {
+	if err.Kind == nil || target == nil {
+		return false
+	}
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Type() != reflect.TypeOf(err.Kind) {
+		return false
+	}
+	value.Elem().Set(reflect.ValueOf(err.Kind))
+	return true
+}
+
+// errorKinds are the cparser errors Wrap recognises.
+var errorKinds = []error{ErrNoHandler{}, ErrBadSyntax{}, ErrCommandFailed{}, ErrAmbiguous{}, ErrInvalidFactory{},
+	ErrTimeout{}, ErrCancelled{}, ErrForbidden{}, ErrAliasLoop{}, ErrNoHistory{}}
+
+// Wrap converts an ntoolkit error, like the errors Execute rejects with, into an *Error whose
+// chain follows the inner errors, for errors.Is, errors.As and errors.Unwrap from the standard
+// library. Errors that are not ntoolkit errors, like *SyntaxError, are left as they are.
+//
+//	_, err := p.Wait("put sword", player)
+//	if stderrors.Is(cparser.Wrap(err), cparser.ErrBadSyntax{}) { ... }
+func Wrap(err error) error {
+	if err == nil || !isToolkitError(err) {
+		return err
+	}
+	rtn := &Error{Message: err.Error(), cause: err}
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			rtn.Kind = kind
+			break
+		}
+	}
+	if inner, ok := errors.Inner(err); ok {
+		rtn.inner = Wrap(inner)
+	}
+	return rtn
+}
+
+// isToolkitError returns true for errors made by ntoolkit/errors.
+func isToolkitError(err error) bool {
+	return packageOf(err) == toolkitErrors
+}
+
+func packageOf(err error) string {
+	t := reflect.TypeOf(err)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.PkgPath()
+}
+
+var toolkitErrors = packageOf(errors.Fail(ErrCommandFailed{}, nil, ""))
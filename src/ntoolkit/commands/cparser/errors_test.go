@@ -0,0 +1,70 @@
+package cparser_test
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func TestWrapNoHandler(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		_, err := fixture().Wait("dance", nil)
+		wrapped := cparser.Wrap(err)
+		T.Assert(wrapped.Error() == err.Error())
+		T.Assert(stderrors.Is(wrapped, cparser.ErrNoHandler{}))
+		T.Assert(!stderrors.Is(wrapped, cparser.ErrBadSyntax{}))
+
+		var noHandler cparser.ErrNoHandler
+		T.Assert(stderrors.As(wrapped, &noHandler))
+		var cparserErr *cparser.Error
+		T.Assert(stderrors.As(wrapped, &cparserErr))
+		T.Assert(cparserErr.Kind == cparser.ErrNoHandler{})
+	})
+}
+
+func TestWrapHandlerError(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		_, err := fixture().Wait("use \"magic hammer\" on door", nil)
+		wrapped := cparser.Wrap(err)
+		T.Assert(stderrors.Is(wrapped, cparser.ErrCommandFailed{}))
+
+		var handlerErr *cparser.Error
+		T.Assert(stderrors.As(stderrors.Unwrap(wrapped), &handlerErr))
+		T.Assert(handlerErr.Kind == nil)
+		T.Assert(errors.Is(handlerErr.Cause(), UseCommandErrBadTool{}))
+	})
+}
+
+func TestWrapSyntaxError(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		_, _, err := syntaxFixture().Parse("put sword into table", nil)
+		wrapped := cparser.Wrap(err)
+		T.Assert(stderrors.Is(wrapped, cparser.ErrCommandFailed{}))
+		T.Assert(stderrors.Is(wrapped, cparser.ErrBadSyntax{}))
+		var syntaxErr *cparser.SyntaxError
+		T.Assert(stderrors.As(wrapped, &syntaxErr))
+		T.Assert(syntaxErr.Found == "into")
+	})
+}
+
+func TestWrapContextError(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := fixture().WaitContext(ctx, "go north", nil)
+		T.Assert(stderrors.Is(cparser.Wrap(err), context.Canceled))
+	})
+}
+
+func TestWrapOther(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		T.Assert(cparser.Wrap(nil) == nil)
+		other := stderrors.New("other")
+		T.Assert(cparser.Wrap(other) == other)
+		T.Assert(stderrors.Is(cparser.ErrNoHandler{}, cparser.ErrNoHandler{}))
+	})
+}
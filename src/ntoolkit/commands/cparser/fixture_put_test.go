@@ -29,7 +29,7 @@ func putDefaultHandler(params map[string]string, context interface{}) (commands.
 func registerPutFactory(parser *cparser.CommandParser) {
 	parser.Register(parser.Command("put", "[item]", "on", "[target]").With(putOnHandler))
 	parser.Register(parser.Command("put", "[item]", "in", "[container]").With(putInHandler))
-	parser.Register(parser.Command().Word("put", true).With(putDefaultHandler))
+	parser.Register(parser.Command().Word("put", true).Strict(false).With(putDefaultHandler))
 }
 
 type PutCommand struct {
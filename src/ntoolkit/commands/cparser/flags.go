@@ -0,0 +1,314 @@
+package cparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// standardCommandFlag is a flag of a StandardCommandFactory; "-q", "--count=3".
+type standardCommandFlag struct {
+	// The name of this flag in the params.
+	Name string
+
+	// The ways to write this flag, with their dashes; "-c", "--count".
+	Spellings []string
+
+	// If valued, the flag takes a value; "--count 3", "--count=3", "-c 3" or "-c3".
+	Valued bool
+
+	// If repeated, the flag can be given more than once.
+	Repeated bool
+
+	// The values used if the flag is not given.
+	Defaults []string
+
+	// If set, the value of this flag is converted to this type.
+	Kind TokenType
+}
+
+// standardCommandFlagValue is a flag given in the input, and its value.
+type standardCommandFlagValue struct {
+	flag  *standardCommandFlag
+	raw   string
+	token int
+	span  Span
+}
+
+// standardCommandFlags are the tokens of an input, separated into flags and positional tokens.
+type standardCommandFlags struct {
+	positional []Span
+
+	// The index in the input of each positional token, and then the number of tokens in the input.
+	origin []int
+
+	found []standardCommandFlagValue
+
+	// The first flag that was invalid, if any.
+	err *SyntaxError
+
+	// A valued flag at the end of the input, that is still waiting for its value.
+	pending *standardCommandFlag
+}
+
+// Flag adds a boolean flag to the command syntax, and returns the instance. Spellings start
+// with dashes, eg. Flag("quiet", "-q", "--quiet"); with none, the flag is "--name".
+// Flags can be anywhere in the command string, "-qv" is the same as "-q -v", and everything
+// after "--" is positional. A flag that is given is true in the params; one that isn't is
+// missing, unless it has a Default.
+func (factory *StandardCommandFactory) Flag(name string, spellings ...string) *StandardCommandFactory {
+	return factory.addFlag(name, false, spellings)
+}
+
+// Option adds a flag that takes a value to the command syntax, and returns the instance;
+// "--count 3", "--count=3", "-c 3" and "-c3" are all the same. Use As() to convert the value.
+func (factory *StandardCommandFactory) Option(name string, spellings ...string) *StandardCommandFactory {
+	return factory.addFlag(name, true, spellings)
+}
+
+// Repeated lets the most recently added flag be given more than once, and returns the instance.
+// A repeated boolean flag is the number of times it was given, as an int; "-vvv" is 3.
+// A repeated option has every value, in order; use Params.Strings() or Params.Values().
+// Any other flag that is given more than once is a syntax error.
+func (factory *StandardCommandFactory) Repeated() *StandardCommandFactory {
+	if len(factory.flags) > 0 {
+		factory.flags[len(factory.flags)-1].Repeated = true
+	}
+	return factory
+}
+
+// Default sets the value of the most recently added flag for when it isn't given, and returns
+// the instance. A repeated option can have more than one default value.
+func (factory *StandardCommandFactory) Default(values ...string) *StandardCommandFactory {
+	if len(factory.flags) > 0 {
+		factory.flags[len(factory.flags)-1].Defaults = values
+	}
+	return factory
+}
+
+// addFlag adds a flag, spelled "--name" if no spellings are given.
+func (factory *StandardCommandFactory) addFlag(name string, valued bool, spellings []string) *StandardCommandFactory {
+	if len(spellings) == 0 {
+		spellings = []string{"--" + name}
+	}
+	factory.flags = append(factory.flags, standardCommandFlag{Name: name, Spellings: spellings, Valued: valued})
+	factory.lastFlag = true
+	return factory
+}
+
+// flag returns the flag with a spelling, or nil if there isn't one.
+func (factory *StandardCommandFactory) flag(spelling string) *standardCommandFlag {
+	for i := range factory.flags {
+		for _, existing := range factory.flags[i].Spellings {
+			if existing == spelling {
+				return &factory.flags[i]
+			}
+		}
+	}
+	return nil
+}
+
+// spellings returns every spelling of every flag.
+func (factory *StandardCommandFactory) spellings() []string {
+	rtn := make([]string, 0, len(factory.flags))
+	for i := range factory.flags {
+		rtn = append(rtn, factory.flags[i].Spellings...)
+	}
+	return rtn
+}
+
+// String renders a single flag; "[-q|--quiet]", "[-c|--count=count:int]", "[--tag=tag]..."
+func (flag *standardCommandFlag) String() string {
+	rtn := strings.Join(flag.Spellings, "|")
+	if flag.Valued {
+		rtn += "=" + flag.value()
+	}
+	rtn = fmt.Sprintf("[%s]", rtn)
+	if flag.Repeated {
+		rtn += "..."
+	}
+	return rtn
+}
+
+// value renders the value of a valued flag; "count", "count:int"
+func (flag *standardCommandFlag) value() string {
+	if flag.Kind != nil {
+		return fmt.Sprintf("%s:%s", flag.Name, flag.Kind.Name())
+	}
+	return flag.Name
+}
+
+// kind returns the type the value of a flag is converted to, or nil for strings.
+// Boolean flags are bools, or the number of times they were given if they are repeated.
+func (flag *standardCommandFlag) kind() TokenType {
+	if flag.Valued {
+		return flag.Kind
+	} else if flag.Repeated {
+		return TypeInt
+	}
+	return TypeBool
+}
+
+// isFlag returns true if a token looks like a flag; "-q", "--quiet" or "--", but not "-" or "-3".
+func isFlag(value string) bool {
+	if len(value) < 2 || value[0] != '-' {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(value[1:])
+	return next == '-' || unicode.IsLetter(next)
+}
+
+// splitFlags separates the flags in the tokens from the positional tokens.
+// Quoted tokens, and tokens after "--", are never flags.
+func (factory *StandardCommandFactory) splitFlags(input *Input, tokens []Span) *standardCommandFlags {
+	rtn := &standardCommandFlags{positional: make([]Span, 0, len(tokens)), origin: make([]int, 0, len(tokens)+1)}
+	fail := func(token int, span Span, expected []string, message string) {
+		if rtn.err == nil {
+			rtn.err = factory.flagError(input, token, span, expected, message)
+		}
+	}
+	terminated := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if terminated || input.quoted(token) || !isFlag(token.Value) {
+			rtn.positional = append(rtn.positional, token)
+			rtn.origin = append(rtn.origin, i)
+			continue
+		}
+		if token.Value == "--" {
+			terminated = true
+			continue
+		}
+
+		// Long flags; "--quiet", "--count=3" or "--count 3"
+		if strings.HasPrefix(token.Value, "--") {
+			spelling := token.Value
+			split := strings.Index(spelling, "=")
+			if split >= 0 {
+				spelling = spelling[:split]
+			}
+			flag := factory.flag(spelling)
+			if flag == nil {
+				fail(i, token, factory.spellings(), fmt.Sprintf("Unknown flag %s for command %s", spelling, factory))
+				continue
+			}
+			if !flag.Valued && split >= 0 {
+				fail(i, token, []string{}, fmt.Sprintf("Flag %s in %s doesn't take a value", spelling, factory))
+				continue
+			}
+			if !flag.Valued {
+				rtn.found = append(rtn.found, standardCommandFlagValue{flag: flag, raw: "true", token: i, span: token})
+			} else if split >= 0 {
+				rtn.found = append(rtn.found, standardCommandFlagValue{flag: flag, raw: token.Value[split+1:], token: i, span: subSpan(token, split+1)})
+			} else {
+				i = rtn.next(factory, input, tokens, i, flag, fail)
+			}
+			continue
+		}
+
+		// Short flags; "-q", "-qv", "-c3" or "-c 3"
+		for offset, c := range token.Value[1:] {
+			spelling := "-" + string(c)
+			flag := factory.flag(spelling)
+			if flag == nil {
+				fail(i, token, factory.spellings(), fmt.Sprintf("Unknown flag %s for command %s", spelling, factory))
+				break
+			}
+			if !flag.Valued {
+				rtn.found = append(rtn.found, standardCommandFlagValue{flag: flag, raw: "true", token: i, span: token})
+				continue
+			}
+			rest := 1 + offset + utf8.RuneLen(c)
+			if rest < len(token.Value) {
+				rtn.found = append(rtn.found, standardCommandFlagValue{flag: flag, raw: token.Value[rest:], token: i, span: subSpan(token, rest)})
+			} else {
+				i = rtn.next(factory, input, tokens, i, flag, fail)
+			}
+			break
+		}
+	}
+	rtn.origin = append(rtn.origin, len(tokens))
+	return rtn
+}
+
+// next takes the token after i as the value of a flag, and returns the index of the last token used.
+func (flags *standardCommandFlags) next(factory *StandardCommandFactory, input *Input, tokens []Span, i int, flag *standardCommandFlag, fail func(int, Span, []string, string)) int {
+	if i+1 >= len(tokens) {
+		flags.pending = flag
+		fail(len(tokens), Span{Start: -1, End: -1}, []string{fmt.Sprintf("[%s]", flag.value())}, fmt.Sprintf("Missing value for flag %s in %s", tokens[i].Value, factory))
+		return i
+	}
+	flags.found = append(flags.found, standardCommandFlagValue{flag: flag, raw: tokens[i+1].Value, token: i + 1, span: tokens[i+1]})
+	return i + 1
+}
+
+// subSpan returns the part of a token from offset onwards; eg. the value of "--count=3".
+func subSpan(token Span, offset int) Span {
+	rtn := Span{Value: token.Value[offset:], Start: -1, End: -1}
+	if token.Start >= 0 {
+		rtn.Start = token.Start + offset
+		rtn.End = token.End
+	}
+	return rtn
+}
+
+// setFlags converts the flags that were found, or their defaults, and sets them in the params.
+func (factory *StandardCommandFactory) setFlags(input *Input, flags *standardCommandFlags, policy *MatchPolicy, params *Params) *SyntaxError {
+	for i := range factory.flags {
+		flag := &factory.flags[i]
+		found := make([]standardCommandFlagValue, 0)
+		for _, value := range flags.found {
+			if value.flag == flag {
+				found = append(found, value)
+			}
+		}
+		if len(found) > 1 && !flag.Repeated {
+			return factory.flagError(input, found[1].token, found[1].span, []string{}, fmt.Sprintf("Flag %s can only be given once in %s", strings.Join(flag.Spellings, "|"), factory))
+		}
+		if len(found) > 1 && !flag.Valued {
+			found = []standardCommandFlagValue{{flag: flag, raw: strconv.Itoa(len(found)), token: found[0].token, span: found[0].span}}
+		}
+		if len(found) == 0 {
+			for _, value := range flag.Defaults {
+				found = append(found, standardCommandFlagValue{flag: flag, raw: value, token: -1, span: Span{Value: value, Start: -1, End: -1}})
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		raws := make([]string, len(found))
+		values := make([]interface{}, len(found))
+		for j := range found {
+			raws[j] = found[j].raw
+			values[j] = found[j].raw
+			if kind := flag.kind(); kind != nil {
+				converted, err := convert(kind, policy, found[j].raw)
+				if err != nil {
+					rtn := factory.flagError(input, found[j].token, found[j].span, []string{kind.Name()}, fmt.Sprintf("Invalid value for %s in %s: expected %s, found \"%s\"", flag, factory, kind.Name(), found[j].raw))
+					rtn.Err = err
+					return rtn
+				}
+				values[j] = converted
+			}
+		}
+		span := found[0].span
+		if flag.Valued && flag.Repeated {
+			params.putList(flag.Name, raws, span, values)
+		} else {
+			params.put(flag.Name, raws[0], span, values[0])
+		}
+	}
+	return nil
+}
+
+// flagError returns the SyntaxError for an invalid flag at a token of the input.
+func (factory *StandardCommandFactory) flagError(input *Input, token int, span Span, expected []string, message string) *SyntaxError {
+	rtn := &SyntaxError{Input: input.Raw, Factory: factory, Syntax: factory.String(), Token: token, Start: span.Start, End: span.End, Expected: expected, Found: span.Value, Message: message}
+	if span.Start < 0 && input.Raw != "" {
+		rtn.Start = len(input.Raw)
+		rtn.End = len(input.Raw)
+	}
+	return rtn
+}
@@ -0,0 +1,145 @@
+package cparser_test
+
+import (
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+)
+
+func flagFixture() (*cparser.CommandParser, *cparser.StandardCommandFactory) {
+	p := cparser.New()
+	factory := p.Command("spawn", "[monster]", "[-q|--quiet]", "[-c|--count=count:int]", "[--tag=tag]...").
+		Option("level", "-l", "--level").As(cparser.TypeInt).Default("1").
+		Flag("verbose", "-v").Repeated().
+		Handle(func(params *cparser.Params, context interface{}) (commands.Command, error) {
+			return &GoCommand{}, nil
+		})
+	p.Register(factory)
+	p.Register(p.Command("look", "[direction]").With(lintHandler))
+	return p, factory
+}
+
+func TestFlagSyntax(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		_, factory := flagFixture()
+		T.Assert(factory.String() == "spawn [monster] [-q|--quiet] [-c|--count=count:int] [--tag=tag]... [-l|--level=level:int] [-v]...")
+	})
+}
+
+func TestFlags(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, _ := flagFixture()
+		_, info, err := p.Parse("spawn --count 3 goblin -q --level=5", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("monster") == "goblin")
+		T.Assert(info.Params.Int("count") == 3)
+		T.Assert(info.Params.Bool("quiet"))
+		T.Assert(info.Params.Int("level") == 5)
+		T.Assert(!info.Params.Has("verbose"))
+		span, _ := info.Params.Span("level")
+		T.Assert(span.Start == 34)
+
+		_, info, err = p.Parse("spawn -qc3 -vvv -v goblin", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.Bool("quiet"))
+		T.Assert(info.Params.Int("count") == 3)
+		T.Assert(info.Params.Int("verbose") == 4)
+		T.Assert(info.Params.Int("level") == 1)
+		T.Assert(info.Params.Map()["level"] == "1")
+	})
+}
+
+func TestFlagsWithoutInput(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := cparser.New().Command("spawn", "[monster]", "[-c|--count=count:int]")
+		params, err := parseWith(factory, "spawn -c 2 goblin")
+		T.Assert(err == nil)
+		T.Assert(params["count"] == "2")
+		T.Assert(params["monster"] == "goblin")
+	})
+}
+
+func TestRepeatedOptions(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, _ := flagFixture()
+		_, info, err := p.Parse("spawn --tag angry goblin --tag=small", nil)
+		T.Assert(err == nil)
+		T.Assert(len(info.Params.Strings("tag")) == 2)
+		T.Assert(info.Params.Strings("tag")[1] == "small")
+		T.Assert(info.Params.Values("tag")[0] == "angry")
+		T.Assert(info.Params.Map()["tag"] == "angry,small")
+		T.Assert(len(info.Params.Strings("monster")) == 1)
+		T.Assert(len(info.Params.Strings("count")) == 0)
+	})
+}
+
+func TestFlagTerminator(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, _ := flagFixture()
+		_, info, err := p.Parse("spawn -q -- -goblin", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("monster") == "-goblin")
+
+		_, info, err = p.Parse("spawn \"-goblin\"", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("monster") == "-goblin")
+
+		_, info, err = p.Parse("spawn -3", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("monster") == "-3")
+	})
+}
+
+func TestBadFlags(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, factory := flagFixture()
+		syntax := factory.String()
+		bad := func(command string, message string) {
+			T.Assert(syntaxErrorFor(T, p, command).Message == message)
+		}
+		bad("spawn goblin --loud", "Unknown flag --loud for command "+syntax)
+		bad("spawn goblin -qx", "Unknown flag -x for command "+syntax)
+		bad("spawn goblin --quiet=yes", "Flag --quiet in "+syntax+" doesn't take a value")
+		bad("spawn goblin --count", "Missing value for flag --count in "+syntax)
+		bad("spawn goblin -q -q", "Flag -q|--quiet can only be given once in "+syntax)
+		bad("spawn goblin -c many", "Invalid value for [-c|--count=count:int] in "+syntax+": expected int, found \"many\"")
+
+		syntaxErr := syntaxErrorFor(T, p, "spawn goblin --loud")
+		T.Assert(syntaxErr.Token == 2)
+		T.Assert(syntaxErr.Found == "--loud")
+		T.Assert(syntaxErr.Start == 13)
+
+		// Flags don't make other commands fail
+		_, info, err := p.Parse("look --loud", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("direction") == "--loud")
+	})
+}
+
+func TestFlagsWithGreedyTokens(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.Register(p.Command("say", "[message...]", "[--loud]").With(lintHandler))
+		_, info, err := p.Parse("say hello  --loud there", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("message") == "hello there")
+		T.Assert(info.Params.Bool("loud"))
+
+		_, info, err = p.Parse("say hello  there --loud", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("message") == "hello  there")
+	})
+}
+
+func TestCompleteFlags(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p, _ := flagFixture()
+		completions := p.Complete("spawn --c", nil)
+		T.Assert(len(completions) == 1)
+		T.Assert(completions[0].Text == "--count")
+		T.Assert(len(p.Complete("look --c", nil)) == 0)
+		T.Assert(len(p.Complete("spawn -q ", nil)) == 0)
+	})
+}
@@ -1,3 +1,3 @@
 package cparser
 //go:generate go run ../../../vendor/ntoolkit/futures/gen/gen.go -packageName cparser -typeImport ntoolkit/commands -typeName Command -typeValue commands.Command -output gen_deferred_command.go
-
+//go:generate go run ../../../vendor/ntoolkit/futures/gen/gen.go -packageName cparser -typeName Sequence -typeValue *Sequence -output gen_deferred_sequence.go
@@ -0,0 +1,64 @@
+
+// Generated by ntoolkit/futures
+package cparser
+
+import "ntoolkit/futures"
+
+
+
+type DeferredSequence struct {
+	DeferredValue futures.Promise
+}
+
+func (promise *DeferredSequence) init() {
+	if promise.DeferredValue == nil {
+		promise.DeferredValue = &futures.DeferredValue{}
+	}
+}
+
+func (promise *DeferredSequence) Resolve(result *Sequence) {
+	promise.init()
+	promise.DeferredValue.PResolve(result)
+}
+
+func (promise *DeferredSequence) Reject(err error) {
+	promise.init()
+	promise.DeferredValue.PReject(err)
+}
+
+func (promise *DeferredSequence) Errors() []error {
+	promise.init()
+	return promise.DeferredValue.PErrors()
+}
+
+func (promise *DeferredSequence) Then(resolve func(*Sequence), reject func(error)) *DeferredSequence {
+	promise.init()
+	promise.DeferredValue.PThen(func(value interface{}) {
+		if v, ok := value.(*Sequence); ok {
+			resolve(v)
+		} else {
+		  panic("Invalid value used to resolve DeferredSequence")
+		}
+	}, reject)
+	return promise
+}
+
+func (promise *DeferredSequence) PThen(result func(interface{}), reject func(error)) futures.Promise {
+	promise.init()
+	return promise.DeferredValue.PThen(result, reject)
+}
+
+func (promise *DeferredSequence) PErrors() []error {
+	promise.init()
+	return promise.DeferredValue.PErrors()
+}
+
+func (promise *DeferredSequence) PResolve(result interface{}) {
+	promise.init()
+	promise.DeferredValue.PResolve(result)
+}
+
+func (promise *DeferredSequence) PReject(err error) {
+	promise.init()
+	promise.DeferredValue.PReject(err)
+}
@@ -0,0 +1,237 @@
+package cparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GrammarError is returned by Grammar when a grammar string is invalid.
+type GrammarError struct {
+	// Grammar is the grammar string that failed to compile.
+	Grammar string
+
+	// Column is the 1-based column of the problem in Grammar.
+	Column int
+
+	// Message describes the problem.
+	Message string
+}
+
+func (err *GrammarError) Error() string {
+	return fmt.Sprintf("Invalid grammar \"%s\" at column %d: %s", err.Grammar, err.Column, err.Message)
+}
+
+// Grammar compiles a single string grammar into a standard command factory:
+//
+//	word            a literal word, like 'put'
+//	(on|onto)       any one of the words
+//	<name>          a token, as Token()
+//	<name:type>     a typed token, as Token().As(); eg. <count:int>
+//	<name...>       a greedy token, as Token().Greedy()
+//	[item]          an optional word, alternation or token
+//	item?           the same as [item]
+//
+// eg. "put <item> (on|onto) <target> [quietly] <count:int>?"
+func (p *CommandParser) Grammar(grammar string) (*StandardCommandFactory, error) {
+	compiler := &grammarCompiler{parser: p, grammar: grammar, factory: newStandardCommandFactory()}
+	if err := compiler.compile(); err != nil {
+		return nil, err
+	}
+	return compiler.factory, nil
+}
+
+// MustGrammar is the same as Grammar, but panics if the grammar is invalid.
+func (p *CommandParser) MustGrammar(grammar string) *StandardCommandFactory {
+	factory, err := p.Grammar(grammar)
+	if err != nil {
+		panic(err)
+	}
+	return factory
+}
+
+// grammarCompiler is the state of a single Grammar call.
+type grammarCompiler struct {
+	parser  *CommandParser
+	grammar string
+	offset  int
+	factory *StandardCommandFactory
+}
+
+func (c *grammarCompiler) compile() error {
+	c.skipSpace()
+	if c.done() {
+		return c.fail(c.offset, "grammar is empty")
+	}
+	for !c.done() {
+		if err := c.item(); err != nil {
+			return err
+		}
+		if c.peek() == '?' {
+			c.offset++
+			c.factory.Optional()
+		}
+		c.skipSpace()
+	}
+	return nil
+}
+
+// item compiles a single word, alternation, token or optional item.
+func (c *grammarCompiler) item() error {
+	start := c.offset
+	switch c.peek() {
+	case '<':
+		return c.token()
+	case '(':
+		return c.alternatives()
+	case '[':
+		c.offset++
+		c.skipSpace()
+		if c.done() || c.peek() == ']' {
+			return c.fail(start, "optional item is empty")
+		}
+		if c.peek() == '[' {
+			return c.fail(c.offset, "optional items can't be nested")
+		}
+		if err := c.item(); err != nil {
+			return err
+		}
+		c.skipSpace()
+		if c.peek() != ']' {
+			return c.fail(c.offset, "expected ']'; optional items may only contain a single word, alternation or token")
+		}
+		c.offset++
+		c.factory.Optional()
+		return nil
+	case ')', ']', '>', '|', '?':
+		return c.fail(start, fmt.Sprintf("unexpected '%c'", c.peek()))
+	}
+	c.factory.Word(c.word())
+	return nil
+}
+
+// token compiles <name>, <name:type> or <name...>
+func (c *grammarCompiler) token() error {
+	start := c.offset
+	c.offset++
+	depth := 0
+	end := -1
+	for i := c.offset; i < len(c.grammar) && end < 0; i++ {
+		switch c.grammar[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '>':
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return c.fail(start, "expected '>' to close token")
+	}
+	body := c.grammar[c.offset:end]
+	greedy := strings.HasSuffix(body, "...")
+	if greedy {
+		body = body[:len(body)-3]
+	}
+	name := body
+	spec := ""
+	if split := strings.Index(body, ":"); split >= 0 {
+		name = body[:split]
+		spec = body[split+1:]
+	}
+	if name == "" || strings.IndexFunc(name, isGrammarSpecial) >= 0 {
+		return c.fail(c.offset, fmt.Sprintf("invalid token name '%s'", name))
+	}
+	c.factory.Token(name)
+	if spec != "" {
+		kind, ok := c.parser.tokenType(spec)
+		if !ok {
+			return c.fail(c.offset+len(name)+1, fmt.Sprintf("unknown token type '%s'", spec))
+		}
+		c.factory.As(kind)
+	}
+	if greedy {
+		c.factory.Greedy()
+	}
+	c.offset = end + 1
+	return nil
+}
+
+// alternatives compiles (word|word|...)
+func (c *grammarCompiler) alternatives() error {
+	start := c.offset
+	c.offset++
+	words := make([]string, 0)
+	for {
+		c.skipSpace()
+		if c.done() {
+			return c.fail(start, "expected ')' to close alternatives")
+		}
+		word := c.word()
+		if word == "" {
+			return c.fail(c.offset, "expected a word")
+		}
+		words = append(words, word)
+		c.skipSpace()
+		if c.peek() == ')' {
+			c.offset++
+			break
+		}
+		if c.peek() != '|' {
+			return c.fail(c.offset, "expected '|' or ')'")
+		}
+		c.offset++
+	}
+	c.factory.Words(words...)
+	return nil
+}
+
+// word reads a literal word
+func (c *grammarCompiler) word() string {
+	start := c.offset
+	for !c.done() {
+		r, size := utf8.DecodeRuneInString(c.grammar[c.offset:])
+		if unicode.IsSpace(r) || isGrammarSpecial(r) {
+			break
+		}
+		c.offset += size
+	}
+	return c.grammar[start:c.offset]
+}
+
+func (c *grammarCompiler) skipSpace() {
+	for !c.done() {
+		r, size := utf8.DecodeRuneInString(c.grammar[c.offset:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		c.offset += size
+	}
+}
+
+func (c *grammarCompiler) peek() byte {
+	if c.done() {
+		return 0
+	}
+	return c.grammar[c.offset]
+}
+
+func (c *grammarCompiler) done() bool {
+	return c.offset >= len(c.grammar)
+}
+
+// fail returns a GrammarError for the byte offset.
+func (c *grammarCompiler) fail(offset int, message string) error {
+	if offset > len(c.grammar) {
+		offset = len(c.grammar)
+	}
+	return &GrammarError{Grammar: c.grammar, Column: utf8.RuneCountInString(c.grammar[:offset]) + 1, Message: message}
+}
+
+func isGrammarSpecial(r rune) bool {
+	return strings.ContainsRune("<>()[]|?", r)
+}
@@ -0,0 +1,66 @@
+package cparser_test
+
+import (
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+)
+
+func TestGrammar(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory, err := p.Grammar("put <item> (on|onto) <target> [quietly] <count:int>?")
+		T.Assert(err == nil)
+		T.Assert(factory.String() == "put [item] on|onto [target] ?quietly ?[count:int]")
+
+		params, err := parseWith(factory, "put sword onto table")
+		T.Assert(err == nil)
+		T.Assert(params["on"] == "onto")
+		T.Assert(params["target"] == "table")
+
+		params, err = parseWith(factory, "put sword on table quietly 3")
+		T.Assert(err == nil)
+		T.Assert(params["quietly"] == "quietly")
+		T.Assert(params["count"] == "3")
+	})
+}
+
+func TestGrammarTokens(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory, err := p.Grammar("go <dir:enum(north, south)> [<speed>] ")
+		T.Assert(err == nil)
+		T.Assert(factory.String() == "go [dir:enum(north,south)] ?[speed]")
+
+		factory = p.MustGrammar("say <message...>")
+		params, err := parseWith(factory, "say hello world")
+		T.Assert(err == nil)
+		T.Assert(params["message"] == "hello world")
+	})
+}
+
+func TestGrammarErrors(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		failures := map[string]int{
+			"":                   1,
+			"put <item":          5,
+			"put <item:thing>":   11,
+			"put (on|":           5,
+			"put (on onto)":      9,
+			"put [on <target>]":  9,
+			"put ) item":         5,
+			"put <>":             6,
+			"put [":              5,
+			"get <count:int>? ?": 18,
+		}
+		for grammar, column := range failures {
+			_, err := p.Grammar(grammar)
+			T.Assert(err != nil)
+			gerr, ok := err.(*cparser.GrammarError)
+			T.Assert(ok)
+			T.Assert(gerr.Column == column)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package cparser
+
+import (
+	"fmt"
+
+	"ntoolkit/errors"
+)
+
+// Guard returns true if a factory can be used with an execution context.
+type Guard func(context interface{}) bool
+
+// RegisterOption changes how a factory is registered; see Requires and Reveal.
+type RegisterOption func(reg *registration)
+
+// Requires only lets the factory be used when the guard returns true for the execution context.
+// If it doesn't, the factory is invisible to matching, suggestions, help and completion.
+// Every guard on a factory must pass.
+func Requires(guard Guard) RegisterOption {
+	return func(reg *registration) {
+		reg.guards = append(reg.guards, guard)
+	}
+}
+
+// Reveal makes a guarded factory that matches a command reject it with ErrForbidden,
+// instead of acting as if it wasn't there. It is still hidden from help and completion.
+// Whether it matches is decided with Rank, without building the command, so only a
+// RankedCommandFactory like StandardCommandFactory can be revealed.
+func Reveal() RegisterOption {
+	return func(reg *registration) {
+		reg.reveal = true
+	}
+}
+
+// registration is the guards a factory was registered with.
+type registration struct {
+	guards []Guard
+	reveal bool
+}
+
+func newRegistration(options []RegisterOption) *registration {
+	if len(options) == 0 {
+		return nil
+	}
+	rtn := &registration{guards: make([]Guard, 0)}
+	for i := range options {
+		options[i](rtn)
+	}
+	return rtn
+}
+
+// guarded returns true if the factory has any guards.
+func (reg *registration) guarded() bool {
+	return reg != nil && len(reg.guards) > 0
+}
+
+// allows returns true if every guard passes for the context.
+func (reg *registration) allows(context interface{}) bool {
+	if reg == nil {
+		return true
+	}
+	for i := range reg.guards {
+		if !reg.guards[i](context) {
+			return false
+		}
+	}
+	return true
+}
+
+// allows returns true if the factory at offset i can be used with a context, and if not,
+// whether it should still be matched to reject the command with ErrForbidden.
+func (r *registry) allows(i int, context interface{}) (allowed bool, revealed bool) {
+	reg := r.guards[i]
+	if reg.allows(context) {
+		return true, false
+	}
+	return false, reg.reveal
+}
+
+// forbidden returns the error for a revealed factory that matched, but isn't allowed.
+func forbidden(factory CommandFactory) error {
+	return errors.Fail(ErrForbidden{}, nil, fmt.Sprintf("You are not allowed to use this command: %s", describeFactory(factory)))
+}
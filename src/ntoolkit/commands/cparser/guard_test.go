@@ -0,0 +1,154 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+type guardPlayer struct {
+	admin bool
+}
+
+func isAdmin(context interface{}) bool {
+	player, ok := context.(*guardPlayer)
+	return ok && player.admin
+}
+
+func guardFixture() *cparser.CommandParser {
+	p := cparser.New()
+	p.Commands.Register(&GoCommandHandler{})
+	p.Register(p.Command("go", "[direction]").With(lintHandler).Summary("Move in a direction"))
+	p.Register(p.Command("ban", "[player]").With(lintHandler).Summary("Ban a player"), cparser.Requires(isAdmin))
+	p.Register(p.Command().Word("kick", true).Token("player").With(lintHandler).Summary("Kick a player"), cparser.Requires(isAdmin))
+	p.Register(p.Command("shutdown").With(lintHandler), cparser.Requires(isAdmin), cparser.Reveal())
+	return p
+}
+
+func TestGuardedMatching(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := guardFixture()
+		admin := &guardPlayer{admin: true}
+		player := &guardPlayer{}
+
+		_, err := p.Wait("ban bob", admin)
+		T.Assert(err == nil)
+
+		_, err = p.Wait("ban bob", player)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+
+		_, err = p.Wait("go north", player)
+		T.Assert(err == nil)
+	})
+}
+
+func TestGuardedHidesSyntaxErrors(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := guardFixture()
+		_, err := p.Wait("kick", &guardPlayer{admin: true})
+		T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+
+		_, err = p.Wait("kick", &guardPlayer{})
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+		T.Assert(!strings.Contains(err.Error(), "kick"))
+	})
+}
+
+func TestGuardedReveal(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := guardFixture()
+		_, err := p.Wait("shutdown", &guardPlayer{})
+		T.Assert(errors.Is(err, cparser.ErrForbidden{}))
+
+		_, err = p.Wait("shutdown", &guardPlayer{admin: true})
+		T.Assert(err == nil)
+	})
+}
+
+func TestGuardedRevealSkipsHandler(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		calls := 0
+		handler := func(params map[string]string, context interface{}) (commands.Command, error) {
+			calls++
+			return &GoCommand{}, nil
+		}
+		p := cparser.New()
+		p.Commands.Register(&GoCommandHandler{})
+		p.Register(p.Command("shutdown", "[when]").With(handler), cparser.Requires(isAdmin), cparser.Reveal())
+		p.Register(&LookCommandFactory{}, cparser.Requires(isAdmin), cparser.Reveal())
+
+		_, err := p.Wait("shutdown now", &guardPlayer{})
+		T.Assert(errors.Is(err, cparser.ErrForbidden{}))
+		T.Assert(calls == 0)
+
+		_, err = p.Wait("look north", &guardPlayer{})
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+
+		_, err = p.Wait("shutdown now", &guardPlayer{admin: true})
+		T.Assert(err == nil)
+		T.Assert(calls == 1)
+	})
+}
+
+func TestGuardedDispatchSpecific(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := guardFixture()
+		p.SetDispatch(cparser.DispatchSpecific)
+		_, _, err := p.Parse("ban bob", &guardPlayer{})
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+
+		_, _, err = p.Parse("shutdown", &guardPlayer{})
+		T.Assert(errors.Is(err, cparser.ErrForbidden{}))
+
+		_, info, err := p.Parse("ban bob", &guardPlayer{admin: true})
+		T.Assert(err == nil)
+		T.Assert(info.Syntax == "ban [player]")
+	})
+}
+
+func TestGuardedSuggestions(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := guardFixture()
+		T.Assert(p.Suggest("bna bob", &guardPlayer{}) == nil)
+		suggestions := p.Suggest("bna bob", &guardPlayer{admin: true})
+		T.Assert(len(suggestions) == 1)
+		T.Assert(suggestions[0].Syntax == "ban [player]")
+	})
+}
+
+func TestGuardedHelp(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := guardFixture()
+		T.Assert(len(p.Help().Topics()) == 4)
+		T.Assert(len(p.HelpFor(&guardPlayer{admin: true}).Topics()) == 4)
+
+		topics := p.HelpFor(&guardPlayer{}).Topics()
+		T.Assert(len(topics) == 1)
+		T.Assert(topics[0].Name == "go")
+		_, found := p.HelpFor(&guardPlayer{}).Usage("ban")
+		T.Assert(!found)
+	})
+}
+
+func TestUnguardedHelpSkipsGuards(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.Register(p.Command("ban", "[player]").With(lintHandler), cparser.Requires(func(context interface{}) bool {
+			return context.(*guardPlayer).admin
+		}))
+		T.Assert(len(p.Help().Topics()) == 1)
+		T.Assert(len(p.HelpFor(&guardPlayer{}).Topics()) == 0)
+	})
+}
+
+func TestGuardedCompletion(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := guardFixture()
+		T.Assert(len(p.Complete("", &guardPlayer{})) == 1)
+		T.Assert(len(p.Complete("", &guardPlayer{admin: true})) == 4)
+	})
+}
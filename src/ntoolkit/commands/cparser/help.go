@@ -0,0 +1,224 @@
+package cparser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"ntoolkit/commands"
+	"ntoolkit/events"
+	"ntoolkit/futures"
+)
+
+// CommandInfo is the documentation for a standard command, used by the help system.
+type CommandInfo struct {
+	// Summary is a one line description of the command.
+	Summary string
+
+	// Description is a longer description of the command.
+	Description string
+
+	// Examples of the command in use, eg. "put sword on table".
+	Examples []string
+
+	// Category groups commands in the help overview, eg. "Movement".
+	Category string
+}
+
+// HelpEntry is the help for a single standard command.
+type HelpEntry struct {
+	Syntax string
+	Info   CommandInfo
+}
+
+// HelpTopic is every standard command that starts with the same word.
+type HelpTopic struct {
+	// Name is the first word of the commands, eg. "put".
+	Name string
+
+	// Category is the category of the first command in the topic that has one.
+	Category string
+
+	Entries []HelpEntry
+}
+
+// Help renders help from the standard commands registered on a CommandParser.
+type Help struct {
+	parser  *CommandParser
+	guarded bool
+	context interface{}
+}
+
+// Help returns the help system for this parser, with every command, whatever its guards.
+func (p *CommandParser) Help() *Help {
+	return &Help{parser: p}
+}
+
+// HelpFor returns the help system for this parser, without the commands that have
+// guards that reject the execution context.
+func (p *CommandParser) HelpFor(context interface{}) *Help {
+	return &Help{parser: p, guarded: true, context: context}
+}
+
+// Topics returns every help topic, sorted by category and then name.
+func (help *Help) Topics() []HelpTopic {
+	r := help.parser.registry()
+	topics := make([]HelpTopic, 0)
+	index := make(map[string]int)
+	for i := range r.factory {
+		factory, ok := r.factory[i].(*StandardCommandFactory)
+		if !ok {
+			continue
+		}
+		if help.guarded {
+			if allowed, _ := r.allows(i, help.context); !allowed {
+				continue
+			}
+		}
+		name := factory.topic()
+		offset, found := index[name]
+		if !found {
+			offset = len(topics)
+			index[name] = offset
+			topics = append(topics, HelpTopic{Name: name})
+		}
+		if topics[offset].Category == "" {
+			topics[offset].Category = factory.info.Category
+		}
+		topics[offset].Entries = append(topics[offset].Entries, HelpEntry{Syntax: factory.String(), Info: factory.info})
+	}
+	sort.SliceStable(topics, func(i, j int) bool {
+		if topics[i].Category != topics[j].Category {
+			return topics[i].Category < topics[j].Category
+		}
+		return topics[i].Name < topics[j].Name
+	})
+	return topics
+}
+
+// Topic returns the help topic for a command word, using the match policy of the parser.
+func (help *Help) Topic(name string) (HelpTopic, bool) {
+	policy := help.parser.registry().policy
+	topics := help.Topics()
+	for i := range topics {
+		if policy.Match(name, topics[i].Name) {
+			return topics[i], true
+		}
+	}
+	return HelpTopic{}, false
+}
+
+// Overview renders every command, by category, as plain text.
+func (help *Help) Overview() string {
+	lines := make([]string, 0)
+	category := ""
+	for i, topic := range help.Topics() {
+		if i == 0 || topic.Category != category {
+			category = topic.Category
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			if category != "" {
+				lines = append(lines, fmt.Sprintf("%s:", category))
+			} else {
+				lines = append(lines, "Commands:")
+			}
+		}
+		for _, entry := range topic.Entries {
+			if entry.Info.Summary != "" {
+				lines = append(lines, fmt.Sprintf("  %s - %s", entry.Syntax, entry.Info.Summary))
+			} else {
+				lines = append(lines, fmt.Sprintf("  %s", entry.Syntax))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Usage renders the help for a single command word as plain text, or returns false
+// if no command starts with that word.
+func (help *Help) Usage(name string) (string, bool) {
+	topic, ok := help.Topic(name)
+	if !ok {
+		return "", false
+	}
+	lines := make([]string, 0)
+	for i, entry := range topic.Entries {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, fmt.Sprintf("Usage: %s", entry.Syntax))
+		if entry.Info.Summary != "" {
+			lines = append(lines, fmt.Sprintf("  %s", entry.Info.Summary))
+		}
+		if entry.Info.Description != "" {
+			lines = append(lines, "", fmt.Sprintf("  %s", entry.Info.Description))
+		}
+		if len(entry.Info.Examples) > 0 {
+			lines = append(lines, "", "  Examples:")
+			for _, example := range entry.Info.Examples {
+				lines = append(lines, fmt.Sprintf("    %s", example))
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// HelpCommand is the command generated by the factory from RegisterHelp.
+type HelpCommand struct {
+	eventHandler *events.EventHandler
+
+	// Topic is the topic asked for, or "" for the overview.
+	Topic string
+
+	// Found is false if there is no help for Topic.
+	Found bool
+
+	// Text is the rendered help.
+	Text string
+}
+
+func (cmd *HelpCommand) EventHandler() *events.EventHandler {
+	if cmd.eventHandler == nil {
+		cmd.eventHandler = events.New()
+	}
+	return cmd.eventHandler
+}
+
+// helpCommandHandler resolves every HelpCommand; the text is already rendered.
+type helpCommandHandler struct {
+}
+
+func (handler *helpCommandHandler) Handles() reflect.Type {
+	return reflect.TypeOf(&HelpCommand{})
+}
+
+func (handler *helpCommandHandler) Execute(command interface{}) *futures.Deferred {
+	rtn := &futures.Deferred{}
+	rtn.Resolve()
+	return rtn
+}
+
+// RegisterHelp registers a "help [topic]" command that yields a *HelpCommand with the
+// overview, or the usage of the topic. Pass words to use instead of "help", eg. "help", "?".
+func (p *CommandParser) RegisterHelp(words ...string) *StandardCommandFactory {
+	if len(words) == 0 {
+		words = []string{"help"}
+	}
+	factory := newStandardCommandFactory().Words(words...).Token("topic").Optional().Summary("Show help for a command")
+	factory.Handle(func(params *Params, context interface{}) (commands.Command, error) {
+		if !params.Has("topic") {
+			return &HelpCommand{Found: true, Text: p.HelpFor(context).Overview()}, nil
+		}
+		topic := params.String("topic")
+		text, found := p.HelpFor(context).Usage(topic)
+		if !found {
+			text = fmt.Sprintf("No help available for %s", topic)
+		}
+		return &HelpCommand{Topic: topic, Found: found, Text: text}, nil
+	})
+	p.Register(factory)
+	p.Commands.Register(&helpCommandHandler{})
+	return factory
+}
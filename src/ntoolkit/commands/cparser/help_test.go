@@ -0,0 +1,84 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+)
+
+func helpFixture() *cparser.CommandParser {
+	p := cparser.New()
+	p.Register(p.Command("go", "[direction]").With(lintHandler).
+		Summary("Move in a direction").
+		Category("Movement").
+		Example("go north"))
+	p.Register(p.Command("put", "[item]", "on", "[target]").With(lintHandler).
+		Summary("Put an item on something").
+		Description("The item must be in your inventory.").
+		Category("Items").
+		Example("put sword on table", "put cup on shelf"))
+	p.Register(p.Command("put", "[item]", "in", "[container]").With(lintHandler).
+		Summary("Put an item in a container"))
+	p.Register(&GoCommandFactory{})
+	return p
+}
+
+func TestHelpTopics(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := helpFixture()
+		topics := p.Help().Topics()
+		T.Assert(len(topics) == 2)
+		T.Assert(topics[0].Name == "put")
+		T.Assert(topics[0].Category == "Items")
+		T.Assert(len(topics[0].Entries) == 2)
+		T.Assert(topics[0].Entries[1].Syntax == "put [item] in [container]")
+		T.Assert(topics[1].Name == "go")
+		T.Assert(topics[1].Entries[0].Info.Examples[0] == "go north")
+	})
+}
+
+func TestHelpText(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := helpFixture()
+		overview := p.Help().Overview()
+		T.Assert(strings.Contains(overview, "Items:\n  put [item] on [target] - Put an item on something"))
+		T.Assert(strings.Contains(overview, "Movement:\n  go [direction] - Move in a direction"))
+
+		usage, ok := p.Help().Usage("put")
+		T.Assert(ok)
+		T.Assert(strings.Contains(usage, "Usage: put [item] on [target]"))
+		T.Assert(strings.Contains(usage, "The item must be in your inventory."))
+		T.Assert(strings.Contains(usage, "    put cup on shelf"))
+		T.Assert(strings.Contains(usage, "Usage: put [item] in [container]"))
+
+		_, ok = p.Help().Usage("fly")
+		T.Assert(!ok)
+	})
+}
+
+func TestRegisterHelp(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := helpFixture()
+		p.RegisterHelp("help", "?")
+
+		cmd, err := p.Wait("help", nil)
+		T.Assert(err == nil)
+		help, ok := cmd.(*cparser.HelpCommand)
+		T.Assert(ok)
+		T.Assert(help.Found)
+		T.Assert(strings.Contains(help.Text, "help|? ?[topic] - Show help for a command"))
+
+		cmd, err = p.Wait("? go", nil)
+		T.Assert(err == nil)
+		help = cmd.(*cparser.HelpCommand)
+		T.Assert(help.Topic == "go")
+		T.Assert(strings.Contains(help.Text, "Usage: go [direction]"))
+
+		cmd, err = p.Wait("help fly", nil)
+		T.Assert(err == nil)
+		help = cmd.(*cparser.HelpCommand)
+		T.Assert(!help.Found)
+	})
+}
@@ -0,0 +1,209 @@
+package cparser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"ntoolkit/commands"
+	"ntoolkit/errors"
+)
+
+// DefaultHistoryCapacity is how many commands History keeps for each owner, if not told otherwise.
+const DefaultHistoryCapacity = 100
+
+// DefaultRepeatWords repeat the last command in the history.
+var DefaultRepeatWords = []string{"!!", "again", "g"}
+
+// HistoryEntry is a command string that was executed, and its outcome.
+type HistoryEntry struct {
+	// Raw is the command string, after any repeat or recall was expanded.
+	Raw string
+
+	// Syntax is the syntax of the factory that matched, if any.
+	Syntax string
+
+	// Err is the error the command was rejected with, if any.
+	Err error
+}
+
+// HistoryStore keeps the history of each owner, oldest first. Implement it to persist history;
+// it must be safe for concurrent use.
+type HistoryStore interface {
+	// Append adds an entry, and then drops the oldest entries until there are no more than capacity.
+	Append(owner string, entry HistoryEntry, capacity int)
+	Entries(owner string) []HistoryEntry
+	Clear(owner string)
+}
+
+// History records the commands executed for each owner, and expands the repeat words
+// ("!!", "again", "g") to the last command, and "!prefix" to the last command starting with prefix.
+type History struct {
+	parser   *CommandParser
+	identity Identity
+	store    HistoryStore
+	capacity int
+	repeat   []string
+	lock     sync.RWMutex
+}
+
+// History adds a command history to the parser, for each owner returned by identity.
+// History is kept in the store, if one is given, or in memory. Call it once per parser;
+// it adds middleware that expands and records commands at StageInput. Add it before
+// Aliases to record commands as they were typed, rather than as they were expanded.
+func (p *CommandParser) History(identity Identity, store ...HistoryStore) *History {
+	rtn := &History{parser: p, identity: identity, capacity: DefaultHistoryCapacity, repeat: DefaultRepeatWords}
+	if len(store) > 0 && store[0] != nil {
+		rtn.store = store[0]
+	} else {
+		rtn.store = NewHistoryStore()
+	}
+	p.Use(rtn.middleware)
+	return rtn
+}
+
+// Capacity sets how many commands are kept for each owner.
+func (history *History) Capacity(capacity int) *History {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	history.capacity = capacity
+	return history
+}
+
+// Repeat sets the words that repeat the last command, instead of DefaultRepeatWords.
+func (history *History) Repeat(words ...string) *History {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	history.repeat = words
+	return history
+}
+
+// Entries returns the history for the owner of the context, oldest first.
+func (history *History) Entries(context interface{}) []HistoryEntry {
+	owner := history.identity(context)
+	if owner == "" {
+		return []HistoryEntry{}
+	}
+	return history.store.Entries(owner)
+}
+
+// Last returns the most recent command for the owner of the context.
+func (history *History) Last(context interface{}) (HistoryEntry, bool) {
+	return history.Recall(context, "")
+}
+
+// Recall returns the most recent command for the owner of the context that starts with prefix.
+func (history *History) Recall(context interface{}, prefix string) (HistoryEntry, bool) {
+	entries := history.Entries(context)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if strings.HasPrefix(entries[i].Raw, prefix) {
+			return entries[i], true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// Clear forgets the history of the owner of the context.
+func (history *History) Clear(context interface{}) {
+	owner := history.identity(context)
+	if owner != "" {
+		history.store.Clear(owner)
+	}
+}
+
+// Expand returns the command a repeat word or "!prefix" refers to, or the command string
+// unchanged if it is neither. It fails with ErrNoHistory if there is no such command.
+func (history *History) Expand(command string, context interface{}) (string, error) {
+	trimmed := strings.TrimSpace(command)
+	history.lock.RLock()
+	repeat := history.repeat
+	history.lock.RUnlock()
+	policy := history.parser.registry().policy
+	if trimmed != "" && policy.Match(trimmed, repeat...) {
+		entry, ok := history.Last(context)
+		if !ok {
+			return "", errors.Fail(ErrNoHistory{}, nil, "No command to repeat")
+		}
+		return entry.Raw, nil
+	}
+	if len(trimmed) > 1 && trimmed[0] == '!' && !strings.ContainsAny(trimmed, " \t") {
+		entry, ok := history.Recall(context, trimmed[1:])
+		if !ok {
+			return "", errors.Fail(ErrNoHistory{}, nil, fmt.Sprintf("No command in history starts with %s", trimmed[1:]))
+		}
+		return entry.Raw, nil
+	}
+	return command, nil
+}
+
+// middleware expands repeats and recalls, and records each command once it settles.
+func (history *History) middleware(next Handler) Handler {
+	return func(call *Call) *DeferredCommand {
+		if call.Stage != StageInput {
+			return next(call)
+		}
+		owner := history.identity(call.Context)
+		if owner == "" {
+			return next(call)
+		}
+		command, err := history.Expand(call.Raw, call.Context)
+		if err != nil {
+			return history.parser.failed(err)
+		}
+		call.Raw = command
+		if strings.TrimSpace(command) == "" {
+			return next(call)
+		}
+		return next(call).Then(func(cmd commands.Command) {
+			history.record(owner, command, call, nil)
+		}, func(err error) {
+			history.record(owner, command, call, err)
+		})
+	}
+}
+
+// record adds a command to the history; later middleware may have changed call.Raw, so the
+// command string is passed in as it was seen.
+func (history *History) record(owner string, command string, call *Call, err error) {
+	entry := HistoryEntry{Raw: command, Err: err}
+	if call.Info != nil {
+		entry.Syntax = call.Info.Syntax
+	}
+	history.lock.RLock()
+	capacity := history.capacity
+	history.lock.RUnlock()
+	history.store.Append(owner, entry, capacity)
+}
+
+// memoryHistoryStore keeps history in memory.
+type memoryHistoryStore struct {
+	lock    sync.RWMutex
+	entries map[string][]HistoryEntry
+}
+
+// NewHistoryStore returns a HistoryStore that keeps history in memory.
+func NewHistoryStore() HistoryStore {
+	return &memoryHistoryStore{entries: make(map[string][]HistoryEntry)}
+}
+
+func (store *memoryHistoryStore) Append(owner string, entry HistoryEntry, capacity int) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	entries := append(store.entries[owner], entry)
+	if capacity >= 0 && len(entries) > capacity {
+		entries = append(make([]HistoryEntry, 0, capacity), entries[len(entries)-capacity:]...)
+	}
+	store.entries[owner] = entries
+}
+
+func (store *memoryHistoryStore) Entries(owner string) []HistoryEntry {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return append(make([]HistoryEntry, 0, len(store.entries[owner])), store.entries[owner]...)
+}
+
+func (store *memoryHistoryStore) Clear(owner string) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	delete(store.entries, owner)
+}
@@ -0,0 +1,117 @@
+package cparser_test
+
+import (
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func TestHistoryRecords(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		history := p.History(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		_, err := p.Wait("go north", bob)
+		T.Assert(err == nil)
+		_, err = p.Wait("dance", bob)
+		T.Assert(err != nil)
+		_, err = p.Wait("look south", nil)
+		T.Assert(err == nil)
+
+		entries := history.Entries(bob)
+		T.Assert(len(entries) == 2)
+		T.Assert(entries[0].Raw == "go north")
+		T.Assert(entries[0].Syntax == "*cparser_test.GoCommandFactory")
+		T.Assert(entries[0].Err == nil)
+		T.Assert(entries[1].Raw == "dance")
+		T.Assert(errors.Is(entries[1].Err, cparser.ErrNoHandler{}))
+		T.Assert(len(history.Entries(nil)) == 0)
+
+		history.Clear(bob)
+		T.Assert(len(history.Entries(bob)) == 0)
+	})
+}
+
+func TestHistoryRepeat(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		history := p.History(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		_, err := p.Wait("!!", bob)
+		T.Assert(errors.Is(err, cparser.ErrNoHistory{}))
+
+		_, err = p.Wait("go north", bob)
+		T.Assert(err == nil)
+		for _, repeat := range []string{"!!", "again", "g"} {
+			cmd, err := p.Wait(repeat, bob)
+			T.Assert(err == nil)
+			T.Assert(cmd.(*GoCommand).Direction == "north")
+		}
+		T.Assert(len(history.Entries(bob)) == 4)
+		T.Assert(history.Entries(bob)[3].Raw == "go north")
+
+		history.Repeat("!!")
+		_, err = p.Wait("g", bob)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+	})
+}
+
+func TestHistoryRecall(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		history := p.History(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		p.Wait("go north", bob)
+		p.Wait("look east", bob)
+		p.Wait("go south", bob)
+
+		cmd, err := p.Wait("!go", bob)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*GoCommand).Direction == "south")
+
+		cmd, err = p.Wait("!lo", bob)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*LookCommand).Direction == "east")
+
+		_, err = p.Wait("!put", bob)
+		T.Assert(errors.Is(err, cparser.ErrNoHistory{}))
+
+		entry, ok := history.Recall(bob, "go n")
+		T.Assert(ok)
+		T.Assert(entry.Raw == "go north")
+	})
+}
+
+func TestHistoryCapacity(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		store := cparser.NewHistoryStore()
+		history := p.History(aliasIdentity, store).Capacity(2)
+		bob := &aliasPlayer{name: "bob"}
+		p.Wait("go north", bob)
+		p.Wait("go south", bob)
+		p.Wait("go east", bob)
+		entries := history.Entries(bob)
+		T.Assert(len(entries) == 2)
+		T.Assert(entries[0].Raw == "go south")
+		T.Assert(len(store.Entries("bob")) == 2)
+	})
+}
+
+func TestHistoryWithAliases(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		history := p.History(aliasIdentity)
+		aliases := p.Aliases(aliasIdentity)
+		bob := &aliasPlayer{name: "bob"}
+		T.Assert(aliases.Define(bob, "n", "go north") == nil)
+		_, err := p.Wait("n", bob)
+		T.Assert(err == nil)
+		cmd, err := p.Wait("!!", bob)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*GoCommand).Direction == "north")
+		T.Assert(history.Entries(bob)[0].Raw == "n")
+	})
+}
@@ -0,0 +1,137 @@
+package cparser
+
+import (
+	"context"
+	"strings"
+
+	"ntoolkit/commands"
+	"ntoolkit/parser"
+)
+
+// Input is a single command string as it is handed to each CommandFactory.
+type Input struct {
+	// Raw is the original command string; it may be empty if the factory was
+	// invoked directly with a token list.
+	Raw string
+
+	// Tokens is the token stream generated from Raw.
+	Tokens *parser.Tokens
+
+	// Context is the execution context passed to Execute.
+	Context interface{}
+
+	// Ctx is the context.Context passed to ExecuteContext; context.Background() for Execute.
+	Ctx context.Context
+
+	// Policy is the match policy of the CommandParser; factories without
+	// their own policy should use it to compare words.
+	Policy *MatchPolicy
+
+	// spans are the locations of the tokens, if the tokenizer reported them.
+	spans []Span
+}
+
+// Span is a token, or run of tokens, and its location in the raw input.
+// Start and End are byte offsets into Input.Raw, or -1 if the token could not be located.
+type Span struct {
+	Value string
+	Start int
+	End   int
+}
+
+// parse dispatches the input to the given factory.
+func (input *Input) parse(factory CommandFactory) (commands.Command, error) {
+	if inputFactory, ok := factory.(InputCommandFactory); ok {
+		return inputFactory.ParseInput(input)
+	}
+	return factory.Parse(input.Tokens, input.Context)
+}
+
+// tokens returns the token values in the input, along with their location in the raw input.
+func (input *Input) tokens() []Span {
+	if input.spans != nil {
+		return append(make([]Span, 0, len(input.spans)), input.spans...)
+	}
+	rtn := make([]Span, 0)
+	if input.Tokens == nil {
+		return rtn
+	}
+	cursor := 0
+	for marker := input.Tokens.Front; marker != nil; marker = marker.Next {
+		token := Span{Value: marker.CollectRaw(" "), Start: -1, End: -1}
+		token.Start, token.End = input.locate(token.Value, cursor)
+		if token.End >= 0 {
+			cursor = token.End
+		}
+		rtn = append(rtn, token)
+	}
+	return rtn
+}
+
+// locate finds the span of a token value in the raw input, starting at cursor.
+// Quoted blocks are located by their first and last word, and include the quotes.
+func (input *Input) locate(value string, cursor int) (int, int) {
+	words := strings.Fields(value)
+	if len(words) == 0 || cursor > len(input.Raw) {
+		return -1, -1
+	}
+	offset := strings.Index(input.Raw[cursor:], words[0])
+	if offset < 0 {
+		return -1, -1
+	}
+	start := cursor + offset
+	if start == cursor || !isQuote(input.Raw[start-1]) {
+		if strings.HasPrefix(input.Raw[start:], value) {
+			return start, start + len(value)
+		}
+		return start, start + len(words[0])
+	}
+	quote := input.Raw[start-1]
+	end := strings.IndexByte(input.Raw[start:], quote)
+	if end < 0 {
+		return start - 1, len(input.Raw)
+	}
+	return start - 1, start + end + 1
+}
+
+// cover returns the span covering the tokens from first up to (not including) last.
+func (input *Input) cover(tokens []Span, first int, last int) Span {
+	rtn := Span{Value: input.span(tokens, first, last), Start: -1, End: -1}
+	if first < last && tokens[first].Start >= 0 && tokens[last-1].End >= 0 {
+		rtn.Start = tokens[first].Start
+		rtn.End = tokens[last-1].End
+	}
+	return rtn
+}
+
+// span returns the raw text covering the tokens from first up to (not including) last,
+// with the original spacing, or the token values joined with a space if the raw input
+// isn't available.
+func (input *Input) span(tokens []Span, first int, last int) string {
+	if first >= last {
+		return ""
+	}
+	start := tokens[first].Start
+	end := tokens[last-1].End
+	if start >= 0 && end >= start {
+		return input.Raw[start:end]
+	}
+	buffer := make([]string, 0, last-first)
+	for i := first; i < last; i++ {
+		buffer = append(buffer, tokens[i].Value)
+	}
+	return strings.Join(buffer, " ")
+}
+
+// quoted returns true if a token was quoted or escaped in the raw input, so it is never
+// taken as a separator or an alias.
+func (input *Input) quoted(token Span) bool {
+	if token.Start < 0 || token.End > len(input.Raw) {
+		return false
+	}
+	return isQuote(input.Raw[token.Start]) || input.Raw[token.Start:token.End] != token.Value
+}
+
+func isQuote(c byte) bool {
+	return c == '"' || c == '\''
+}
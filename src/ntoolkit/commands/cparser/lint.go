@@ -0,0 +1,334 @@
+package cparser
+
+import (
+	"fmt"
+	"strings"
+
+	"ntoolkit/errors"
+)
+
+// LintKind is the kind of problem found by Lint.
+type LintKind int
+
+const (
+	// LintNoHandler is a factory that never had With() or Handle() called on it.
+	LintNoHandler LintKind = iota
+
+	// LintDuplicate is a factory that accepts exactly the same input as an earlier one.
+	LintDuplicate
+
+	// LintShadowed is a factory that can never be used, because an earlier factory
+	// accepts everything it does. Only reported for DispatchFirst.
+	LintShadowed
+
+	// LintOverlap is a factory that accepts some of the same input as an earlier one.
+	// This is often intended, so Validate ignores it.
+	LintOverlap
+
+	// LintInvalid is a factory whose syntax couldn't be used, eg. a token with an unknown type.
+	LintInvalid
+)
+
+// LintIssue is a problem with a registered standard command factory.
+type LintIssue struct {
+	Kind LintKind
+
+	// Factory is the factory with the problem.
+	Factory *StandardCommandFactory
+
+	// Other is the earlier factory it conflicts with, if any.
+	Other *StandardCommandFactory
+
+	// Message describes the problem.
+	Message string
+}
+
+func (issue LintIssue) String() string {
+	return issue.Message
+}
+
+// Lint checks every registered standard command factory for missing handlers, and
+// for syntax that is duplicated, shadowed by, or overlapping with an earlier factory.
+// Other kinds of CommandFactory are not checked, and factories registered with guards are
+// not compared with each other or with unguarded factories, as they may apply to different contexts.
+// Factories with different flags are never duplicates or shadowed, as the flags tell them apart.
+func (p *CommandParser) Lint() []LintIssue {
+	r := p.registry()
+	issues := make([]LintIssue, 0)
+	factories := make([]*StandardCommandFactory, 0)
+	shapes := make([][]lintShape, 0)
+	guarded := make([]bool, 0)
+	for i := range r.factory {
+		factory, ok := r.factory[i].(*StandardCommandFactory)
+		if !ok {
+			continue
+		}
+		if factory.invalid != "" {
+			issues = append(issues, LintIssue{
+				Kind:    LintInvalid,
+				Factory: factory,
+				Message: fmt.Sprintf("%s: %s", factory, factory.invalid)})
+		}
+		if factory.handler == nil {
+			issues = append(issues, LintIssue{
+				Kind:    LintNoHandler,
+				Factory: factory,
+				Message: fmt.Sprintf("%s: no handler; call With() or Handle()", factory)})
+		}
+		shape := lintShapes(factory, r.factoryPolicy(factory))
+		for j := range factories {
+			other := factories[j]
+			if guarded[j] || r.guards[i].guarded() {
+				continue
+			}
+			sameFlags := lintSameFlags(other, factory)
+			if sameFlags && lintCoversAll(shapes[j], shape) && lintCoversAll(shape, shapes[j]) {
+				issues = append(issues, LintIssue{
+					Kind:    LintDuplicate,
+					Factory: factory,
+					Other:   other,
+					Message: fmt.Sprintf("%s: duplicates %s", factory, other)})
+				break
+			} else if sameFlags && r.dispatch == DispatchFirst && lintCoversAll(shapes[j], shape) {
+				issues = append(issues, LintIssue{
+					Kind:    LintShadowed,
+					Factory: factory,
+					Other:   other,
+					Message: fmt.Sprintf("%s: is shadowed by %s", factory, other)})
+				break
+			} else if lintOverlapsAny(shapes[j], shape) {
+				issues = append(issues, LintIssue{
+					Kind:    LintOverlap,
+					Factory: factory,
+					Other:   other,
+					Message: fmt.Sprintf("%s: overlaps %s", factory, other)})
+			}
+		}
+		factories = append(factories, factory)
+		shapes = append(shapes, shape)
+		guarded = append(guarded, r.guards[i].guarded())
+	}
+	return issues
+}
+
+// Validate returns an ErrInvalidFactory error listing every issue from Lint,
+// other than overlaps, or nil if there are none.
+func (p *CommandParser) Validate() error {
+	problems := make([]string, 0)
+	for _, issue := range p.Lint() {
+		if issue.Kind != LintOverlap {
+			problems = append(problems, issue.Message)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.Fail(ErrInvalidFactory{}, nil, fmt.Sprintf("Invalid command factories: %s", strings.Join(problems, "; ")))
+}
+
+// lintSameFlags returns true if two factories accept the same flags.
+func lintSameFlags(a *StandardCommandFactory, b *StandardCommandFactory) bool {
+	if len(a.flags) != len(b.flags) {
+		return false
+	}
+	for i := range a.flags {
+		found := false
+		for j := range b.flags {
+			found = found || a.flags[i].String() == b.flags[j].String()
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// lintItem is a single required item of a lintShape.
+type lintItem struct {
+	// words accepted, in their normal form, or nil for a token.
+	words []string
+
+	kind   TokenType
+	greedy bool
+
+	// The policy words are compared under.
+	policy *MatchPolicy
+}
+
+// lintShape is one form of a factory, with each optional item either present or missing.
+type lintShape struct {
+	items []lintItem
+
+	// If loose, any input can follow the items.
+	loose bool
+}
+
+// lintShapes returns every shape of a factory.
+func lintShapes(factory *StandardCommandFactory, policy *MatchPolicy) []lintShape {
+	shapes := []lintShape{{items: make([]lintItem, 0), loose: factory.loose}}
+	for i := range factory.items {
+		source := &factory.items[i]
+		item := lintItem{kind: source.Kind, greedy: source.Greedy, policy: policy}
+		if source.Type == standardCommandTypeWord {
+			words := source.Alternatives
+			if len(words) == 0 {
+				words = []string{source.Name}
+			}
+			for j := range words {
+				item.words = append(item.words, policy.Normal(words[j]))
+			}
+		}
+		next := make([]lintShape, 0, len(shapes)*2)
+		for j := range shapes {
+			with := lintShape{items: append(append([]lintItem{}, shapes[j].items...), item), loose: shapes[j].loose}
+			next = append(next, with)
+			if source.Optional {
+				next = append(next, shapes[j])
+			}
+		}
+		shapes = next
+	}
+	return shapes
+}
+
+// lintCoversAll returns true if every shape in b is covered by some shape in a.
+func lintCoversAll(a []lintShape, b []lintShape) bool {
+	for i := range b {
+		covered := false
+		for j := 0; j < len(a) && !covered; j++ {
+			covered = lintCovers(&a[j], 0, &b[i], 0)
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// lintOverlapsAny returns true if any shape in a can match the same input as any shape in b.
+func lintOverlapsAny(a []lintShape, b []lintShape) bool {
+	for i := range a {
+		for j := range b {
+			if lintOverlaps(&a[i], 0, &b[j], 0) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lintCovers returns true if a accepts everything b accepts, from items i and j onwards.
+// This errs on the side of false when greedy tokens make it hard to tell.
+func lintCovers(a *lintShape, i int, b *lintShape, j int) bool {
+	if j == len(b.items) {
+		if b.loose {
+			return i == len(a.items) && a.loose
+		}
+		return i == len(a.items)
+	}
+	if i == len(a.items) {
+		return a.loose
+	}
+	x, y := a.items[i], b.items[j]
+	if !x.contains(y) {
+		return false
+	}
+	if y.greedy {
+		return x.greedy && lintCovers(a, i+1, b, j+1)
+	}
+	if x.greedy && lintCovers(a, i, b, j+1) {
+		return true
+	}
+	return lintCovers(a, i+1, b, j+1)
+}
+
+// lintOverlaps returns true if some input matches both a and b, from items i and j onwards.
+func lintOverlaps(a *lintShape, i int, b *lintShape, j int) bool {
+	if i == len(a.items) && j == len(b.items) {
+		return true
+	}
+	if i == len(a.items) {
+		return a.loose
+	}
+	if j == len(b.items) {
+		return b.loose
+	}
+	x, y := a.items[i], b.items[j]
+	if !x.intersects(y) {
+		return false
+	}
+	return lintOverlaps(a, i+1, b, j+1) ||
+		(x.greedy && lintOverlaps(a, i, b, j+1)) ||
+		(y.greedy && lintOverlaps(a, i+1, b, j))
+}
+
+// contains returns true if every token other accepts is accepted by item.
+func (item lintItem) contains(other lintItem) bool {
+	if item.words != nil {
+		if other.words == nil {
+			return false
+		}
+		for i := range other.words {
+			if !lintContainsWord(item.words, other.words[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if item.kind == nil {
+		return true
+	}
+	if other.words != nil {
+		for i := range other.words {
+			if _, err := convert(item.kind, item.policy, other.words[i]); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	return other.kind != nil && other.kind.Name() == item.kind.Name()
+}
+
+// intersects returns true if some token is accepted by both item and other.
+func (item lintItem) intersects(other lintItem) bool {
+	if item.words != nil && other.words != nil {
+		for i := range other.words {
+			if lintContainsWord(item.words, other.words[i]) {
+				return true
+			}
+		}
+		return false
+	}
+	if item.words != nil {
+		return other.intersects(item)
+	}
+	if item.kind == nil {
+		return true
+	}
+	if other.words != nil {
+		for i := range other.words {
+			if _, err := convert(item.kind, item.policy, other.words[i]); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	enum, isEnum := item.kind.(*enumTokenType)
+	if isEnum {
+		words := make([]string, len(enum.values))
+		for i := range enum.values {
+			words[i] = item.policy.Normal(enum.values[i])
+		}
+		return other.intersects(lintItem{words: words, policy: item.policy})
+	}
+	return true
+}
+
+func lintContainsWord(words []string, word string) bool {
+	for i := range words {
+		if words[i] == word {
+			return true
+		}
+	}
+	return false
+}
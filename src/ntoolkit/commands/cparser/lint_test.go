@@ -0,0 +1,160 @@
+package cparser_test
+
+import (
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func lintHandler(params map[string]string, context interface{}) (commands.Command, error) {
+	return &GoCommand{}, nil
+}
+
+func lintKinds(issues []cparser.LintIssue) map[cparser.LintKind]int {
+	rtn := make(map[cparser.LintKind]int)
+	for _, issue := range issues {
+		rtn[issue.Kind]++
+	}
+	return rtn
+}
+
+func TestLintClean(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		kinds := lintKinds(p.Lint())
+		T.Assert(len(kinds) == 1)
+		T.Assert(kinds[cparser.LintOverlap] == 2)
+		T.Assert(p.Validate() == nil)
+	})
+}
+
+func TestLintNoHandler(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command("look")
+		p.Register(factory)
+
+		issues := p.Lint()
+		T.Assert(len(issues) == 1)
+		T.Assert(issues[0].Kind == cparser.LintNoHandler)
+		T.Assert(issues[0].Factory == factory)
+		T.Assert(errors.Is(p.Validate(), cparser.ErrInvalidFactory{}))
+	})
+}
+
+func TestLintDuplicate(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		first := p.Command("put|place", "[item]", "?quietly").With(lintHandler)
+		p.Register(first)
+		p.Register(p.Command("place|put", "[thing]", "?quietly").With(lintHandler))
+
+		issues := p.Lint()
+		T.Assert(len(issues) == 1)
+		T.Assert(issues[0].Kind == cparser.LintDuplicate)
+		T.Assert(issues[0].Other == first)
+	})
+}
+
+func TestLintShadowed(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.Register(p.Command().Word("put", true).Strict(false).With(lintHandler))
+		p.Register(p.Command("put", "[item]", "on", "[target]").With(lintHandler))
+		p.Register(p.Command("say", "[message...]").With(lintHandler))
+		p.Register(p.Command("say", "hello", "[who]").With(lintHandler))
+		p.Register(p.Command("go", "[dir]").With(lintHandler))
+		p.Register(p.Command("go", "[dir:enum(north,south)]").With(lintHandler))
+
+		kinds := lintKinds(p.Lint())
+		T.Assert(kinds[cparser.LintShadowed] == 3)
+		T.Assert(errors.Is(p.Validate(), cparser.ErrInvalidFactory{}))
+
+		p.SetDispatch(cparser.DispatchSpecific)
+		kinds = lintKinds(p.Lint())
+		T.Assert(kinds[cparser.LintShadowed] == 0)
+		T.Assert(kinds[cparser.LintOverlap] == 3)
+		T.Assert(p.Validate() == nil)
+	})
+}
+
+func TestLintOverlap(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.Register(p.Command("put", "[item]", "on", "[target]").With(lintHandler))
+		p.Register(p.Command("put", "[item]", "[where]", "[target]").With(lintHandler))
+		p.Register(p.Command("put", "[count:int]", "[item]").With(lintHandler))
+		p.Register(p.Command("go", "[dir:enum(north,south)]").With(lintHandler))
+		p.Register(p.Command("go", "[dir:enum(east,west)]").With(lintHandler))
+
+		issues := p.Lint()
+		T.Assert(len(issues) == 1)
+		T.Assert(issues[0].Kind == cparser.LintOverlap)
+		T.Assert(p.Validate() == nil)
+	})
+}
+
+func TestLintGuarded(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		isPlayer := func(context interface{}) bool {
+			return context == "player"
+		}
+		isOwner := func(context interface{}) bool {
+			return context == "owner"
+		}
+		p := cparser.New()
+		p.Register(p.Command("kick", "[player]").With(lintHandler), cparser.Requires(isOwner))
+		p.Register(p.Command("kick", "[player]").With(lintHandler), cparser.Requires(isPlayer))
+		p.Register(p.Command("look").With(lintHandler))
+		p.Register(p.Command("look").With(lintHandler))
+		issues := p.Lint()
+		T.Assert(len(issues) == 1)
+		T.Assert(issues[0].Kind == cparser.LintDuplicate)
+		T.Assert(issues[0].Factory.String() == "look")
+	})
+}
+
+func TestLintFlags(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.Register(p.Command("spawn", "[monster]").With(lintHandler))
+		quiet := p.Command("spawn", "[monster]", "[-q|--quiet]").With(lintHandler)
+		p.Register(quiet)
+		kinds := lintKinds(p.Lint())
+		T.Assert(kinds[cparser.LintDuplicate] == 0)
+		T.Assert(kinds[cparser.LintShadowed] == 0)
+		T.Assert(p.Validate() == nil)
+
+		_, info, err := p.Parse("spawn goblin -q", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Syntax == quiet.String())
+
+		p.Register(p.Command("spawn", "[thing]", "[-l|--loud]").With(lintHandler))
+		p.Register(p.Command("spawn", "[thing]", "[-q|--quiet]").With(lintHandler))
+		kinds = lintKinds(p.Lint())
+		T.Assert(kinds[cparser.LintDuplicate] == 1)
+	})
+}
+
+func TestLintInvalid(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command("give", "[n:integer]", "[-c|--count=count:many]").With(lintHandler)
+		p.Register(factory)
+
+		issues := p.Lint()
+		T.Assert(len(issues) == 1)
+		T.Assert(issues[0].Kind == cparser.LintInvalid)
+		T.Assert(issues[0].Message == "give [n] [-c|--count=count]: unknown token type 'integer'")
+		T.Assert(errors.Is(p.Validate(), cparser.ErrInvalidFactory{}))
+
+		_, _, err := p.Parse("give 3", nil)
+		T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+		inner, ok := errors.Inner(err)
+		T.Assert(ok)
+		T.Assert(errors.Is(inner, cparser.ErrInvalidFactory{}))
+	})
+}
@@ -0,0 +1,82 @@
+package cparser
+
+import (
+	"strings"
+	"unicode"
+
+	"ntoolkit/parser"
+)
+
+// MatchPolicy controls how the words in a command string are compared to the words
+// in a command syntax. Tokens are never changed by the policy; handlers get them as typed.
+// The zero value compares words exactly.
+type MatchPolicy struct {
+	// FoldCase matches words regardless of case, so "Go North" matches "go [dir]".
+	FoldCase bool
+
+	// TrimPunctuation ignores trailing punctuation on a word, so "look!" matches "look".
+	TrimPunctuation bool
+
+	// Decompose matches accented latin letters however they were typed, so "café" with a
+	// precomposed "é" matches "café" written with "e" and a combining acute accent. It only
+	// knows the precomposed letters of Latin-1 and Latin Extended-A; it is not full Unicode
+	// normalization, so use Normalize with norm.NFD.String for other scripts.
+	Decompose bool
+
+	// Normalize, if set, is applied to both words before they are compared; for
+	// example norm.NFC.String from golang.org/x/text/unicode/norm for other scripts.
+	Normalize func(word string) string
+}
+
+var (
+	// ExactMatch compares words exactly.
+	ExactMatch = MatchPolicy{}
+
+	// LooseMatch ignores case and trailing punctuation, and decomposes accented letters.
+	LooseMatch = MatchPolicy{FoldCase: true, TrimPunctuation: true, Decompose: true}
+)
+
+// Normal returns word in the form it is compared in under this policy.
+func (policy *MatchPolicy) Normal(word string) string {
+	if policy == nil {
+		return word
+	}
+	if policy.TrimPunctuation {
+		trimmed := strings.TrimRightFunc(word, unicode.IsPunct)
+		if trimmed != "" {
+			word = trimmed
+		}
+	}
+	if policy.Decompose {
+		word = decompose(word)
+	}
+	if policy.Normalize != nil {
+		word = policy.Normalize(word)
+	}
+	if policy.FoldCase {
+		word = strings.ToLower(word)
+	}
+	return word
+}
+
+// Match returns true if raw matches any of the given words under this policy.
+func (policy *MatchPolicy) Match(raw string, words ...string) bool {
+	normal := policy.Normal(raw)
+	for i := range words {
+		word := policy.Normal(words[i])
+		if normal == word || (policy != nil && policy.FoldCase && strings.EqualFold(normal, word)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchToken returns true if the token matches any of the given words under this policy;
+// custom CommandFactory implementations should use this to compare words the same way
+// as the standard factories do. A nil token never matches.
+func (policy *MatchPolicy) MatchToken(token *parser.Token, words ...string) bool {
+	if token == nil {
+		return false
+	}
+	return policy.Match(token.CollectRaw(" "), words...)
+}
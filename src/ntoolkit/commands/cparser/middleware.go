@@ -0,0 +1,152 @@
+package cparser
+
+import (
+	"context"
+	"sync"
+
+	"ntoolkit/commands"
+	"ntoolkit/errors"
+	"ntoolkit/futures"
+)
+
+// Stage is the point of ExecuteContext a Call has reached.
+type Stage int
+
+const (
+	// StageInput is the raw command string, before it is tokenized and matched.
+	// Middleware can rewrite Call.Raw here; the DeferredCommand returned is the final result.
+	StageInput Stage = iota
+
+	// StageMatch is a command that has been built by a factory, but not executed.
+	// Middleware can inspect Call.Info or replace Call.Command here.
+	StageMatch
+
+	// StageExecute is the command being executed by the commands object.
+	// The DeferredCommand returned is the result of the execution alone.
+	StageExecute
+)
+
+// Call is a command passing through the middleware chain; the same Call is given to every stage.
+type Call struct {
+	Stage Stage
+
+	// Raw is the command string; middleware may rewrite it at StageInput.
+	Raw string
+
+	// Context is the execution context passed to Execute.
+	Context interface{}
+
+	// Ctx is the context.Context passed to ExecuteContext; context.Background() for Execute.
+	Ctx context.Context
+
+	// Command and Info are set from StageMatch onwards.
+	Command commands.Command
+	Info    *ParseInfo
+}
+
+// Handler handles one stage of a Call.
+type Handler func(call *Call) *DeferredCommand
+
+// Middleware wraps the handler for every stage; it is called once per stage for each command.
+// It can call next, return its own rejected DeferredCommand to stop the command, or wrap the
+// DeferredCommand that next returns. Middleware that only cares about one stage should pass
+// the others straight to next.
+type Middleware func(next Handler) Handler
+
+// Use adds middleware to the parser. The first middleware added is the outermost.
+// Parse doesn't run middleware.
+func (p *CommandParser) Use(middleware ...Middleware) {
+	p.update(func(r *registry) {
+		chain := make([]Middleware, 0, len(r.middleware)+len(middleware))
+		chain = append(chain, r.middleware...)
+		r.middleware = append(chain, middleware...)
+	})
+}
+
+// run passes the call through the middleware chain to the handler for the current stage.
+func (p *CommandParser) run(r *registry, call *Call) *DeferredCommand {
+	handler := p.stage(r, call.Stage)
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	rtn := handler(call)
+	if rtn == nil {
+		return p.failed(errors.Fail(ErrCommandFailed{}, nil, "Middleware did not return a result"))
+	}
+	return rtn
+}
+
+// stage returns the handler at the end of the chain for a stage, which moves the call on to the next one.
+func (p *CommandParser) stage(r *registry, stage Stage) Handler {
+	switch stage {
+	case StageInput:
+		return func(call *Call) *DeferredCommand {
+			cmd, info, err := p.parse(call.Ctx, call.Raw, call.Context)
+			if err != nil {
+				return p.failed(err)
+			}
+			call.Command = cmd
+			call.Info = info
+			call.Stage = StageMatch
+			return p.run(r, call)
+		}
+	case StageMatch:
+		return func(call *Call) *DeferredCommand {
+			call.Stage = StageExecute
+			return p.run(r, call)
+		}
+	}
+	return p.execute
+}
+
+// execute runs the command of a call with the commands object, until it settles or the call's ctx is done.
+func (p *CommandParser) execute(call *Call) *DeferredCommand {
+	cmd := call.Command
+	ctx := call.Ctx
+	rtn := &DeferredCommand{DeferredValue: &futures.DeferredValue{}}
+	done := make(chan bool)
+	settle := &sync.Once{}
+	p.Commands.Execute(cmd).Then(func() {
+		settle.Do(func() {
+			close(done)
+			p.settle(rtn, cmd, nil)
+		})
+	}, func(err error) {
+		settle.Do(func() {
+			close(done)
+			p.settle(rtn, nil, errors.Fail(ErrCommandFailed{}, err, "Command failed to execute"))
+		})
+	})
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				settle.Do(func() {
+					p.settle(rtn, nil, contextError(ctx.Err()))
+				})
+			}
+		}()
+	}
+	return rtn
+}
+
+// settle resolves or rejects the result of a command. The callbacks of the result run here,
+// possibly on the goroutine of the command handler, so a panic in one of them is recovered,
+// and rejects the result if it hasn't already been resolved.
+func (p *CommandParser) settle(rtn *DeferredCommand, cmd commands.Command, err error) {
+	r := p.registry()
+	defer (func() {
+		if value := recover(); value != nil {
+			if r.recovery == Repanic {
+				panic(value)
+			}
+			rtn.Reject(panicked(value))
+		}
+	})()
+	if err != nil {
+		rtn.Reject(err)
+	} else {
+		rtn.Resolve(cmd)
+	}
+}
@@ -0,0 +1,125 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+type ErrMuted struct{}
+
+func TestMiddlewareStages(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		stages := make([]cparser.Stage, 0)
+		p.Use(func(next cparser.Handler) cparser.Handler {
+			return func(call *cparser.Call) *cparser.DeferredCommand {
+				stages = append(stages, call.Stage)
+				if call.Stage == cparser.StageMatch {
+					T.Assert(call.Command.(*GoCommand).Direction == "north")
+					T.Assert(call.Info.Syntax != "")
+				}
+				return next(call)
+			}
+		})
+		_, err := p.Wait("go north", nil)
+		T.Assert(err == nil)
+		T.Assert(len(stages) == 3)
+		T.Assert(stages[0] == cparser.StageInput)
+		T.Assert(stages[1] == cparser.StageMatch)
+		T.Assert(stages[2] == cparser.StageExecute)
+	})
+}
+
+func TestMiddlewareRewriteInput(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		p.Use(func(next cparser.Handler) cparser.Handler {
+			return func(call *cparser.Call) *cparser.DeferredCommand {
+				if call.Stage == cparser.StageInput && call.Raw == "n" {
+					call.Raw = "go north"
+				}
+				return next(call)
+			}
+		})
+		cmd, err := p.Wait("n", nil)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*GoCommand).Direction == "north")
+	})
+}
+
+func TestMiddlewareShortCircuit(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		executed := false
+		p.Use(func(next cparser.Handler) cparser.Handler {
+			return func(call *cparser.Call) *cparser.DeferredCommand {
+				if call.Stage == cparser.StageMatch && call.Context == "muted" {
+					rtn := &cparser.DeferredCommand{}
+					rtn.Reject(errors.Fail(ErrMuted{}, nil, "You can't do that while muted"))
+					return rtn
+				}
+				if call.Stage == cparser.StageExecute {
+					executed = true
+				}
+				return next(call)
+			}
+		})
+		_, err := p.Wait("go north", "muted")
+		T.Assert(errors.Is(err, ErrMuted{}))
+		T.Assert(!executed)
+
+		_, err = p.Wait("go north", nil)
+		T.Assert(err == nil)
+		T.Assert(executed)
+	})
+}
+
+func TestMiddlewareReplaceCommand(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		p.Use(func(next cparser.Handler) cparser.Handler {
+			return func(call *cparser.Call) *cparser.DeferredCommand {
+				if call.Stage == cparser.StageMatch {
+					call.Command = &GoCommand{Direction: "south"}
+				}
+				return next(call)
+			}
+		})
+		cmd, err := p.Wait("go north", nil)
+		T.Assert(err == nil)
+		T.Assert(cmd.(*GoCommand).Direction == "south")
+	})
+}
+
+func TestMiddlewareWrapResult(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		order := make([]string, 0)
+		for _, name := range []string{"outer", "inner"} {
+			name := name
+			p.Use(func(next cparser.Handler) cparser.Handler {
+				return func(call *cparser.Call) *cparser.DeferredCommand {
+					if call.Stage != cparser.StageInput {
+						return next(call)
+					}
+					order = append(order, name)
+					return next(call).Then(func(cmd commands.Command) {
+						order = append(order, name+" done")
+					}, func(err error) {
+						order = append(order, name+" failed")
+					})
+				}
+			})
+		}
+		_, err := p.Wait("go north", nil)
+		T.Assert(err == nil)
+		_, err = p.Wait("nope", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+		T.Assert(strings.Join(order, ", ") == "outer, inner, inner done, outer done, outer, inner, inner failed, outer failed")
+	})
+}
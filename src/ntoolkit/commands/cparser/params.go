@@ -0,0 +1,136 @@
+package cparser
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Params are the values matched by a StandardCommandFactory, by name.
+// Typed tokens and options hold their converted value, boolean flags a bool, repeated
+// options a []interface{} of every value, and everything else is a string.
+// The accessors return the zero value if a name is missing or of another type.
+type Params struct {
+	raw    map[string]string
+	values map[string]interface{}
+	spans  map[string]Span
+	lists  map[string][]string
+	ctx    context.Context
+}
+
+// newParams returns a blank set of params
+func newParams() *Params {
+	return &Params{raw: make(map[string]string), values: make(map[string]interface{}), spans: make(map[string]Span), lists: make(map[string][]string)}
+}
+
+// set assigns the converted value and location of a name
+func (params *Params) set(name string, span Span, value interface{}) {
+	params.put(name, span.Value, span, value)
+}
+
+// put assigns the raw text, converted value and location of a name
+func (params *Params) put(name string, raw string, span Span, value interface{}) {
+	params.raw[name] = raw
+	params.values[name] = value
+	params.spans[name] = span
+}
+
+// putList assigns every value of a repeated option; the raw text is the values joined with commas
+func (params *Params) putList(name string, raw []string, span Span, values []interface{}) {
+	params.put(name, strings.Join(raw, ","), span, values)
+	params.lists[name] = raw
+}
+
+// unset removes a name
+func (params *Params) unset(name string) {
+	delete(params.raw, name)
+	delete(params.values, name)
+	delete(params.spans, name)
+	delete(params.lists, name)
+}
+
+// Context returns the context.Context the command is being parsed with.
+func (params *Params) Context() context.Context {
+	if params.ctx == nil {
+		return context.Background()
+	}
+	return params.ctx
+}
+
+// Has returns true if name was matched; useful for optional items.
+func (params *Params) Has(name string) bool {
+	_, ok := params.raw[name]
+	return ok
+}
+
+// Value returns the value of name, whatever its type.
+func (params *Params) Value(name string) interface{} {
+	return params.values[name]
+}
+
+// String returns the raw text of name, even for typed tokens.
+func (params *Params) String(name string) string {
+	return params.raw[name]
+}
+
+// Int returns the value of an int token.
+func (params *Params) Int(name string) int {
+	value, _ := params.values[name].(int)
+	return value
+}
+
+// Float returns the value of a float token.
+func (params *Params) Float(name string) float64 {
+	value, _ := params.values[name].(float64)
+	return value
+}
+
+// Bool returns the value of a bool token.
+func (params *Params) Bool(name string) bool {
+	value, _ := params.values[name].(bool)
+	return value
+}
+
+// Duration returns the value of a duration token.
+func (params *Params) Duration(name string) time.Duration {
+	value, _ := params.values[name].(time.Duration)
+	return value
+}
+
+// Strings returns the raw text of every value of a repeated option, or of name if it has a single value.
+func (params *Params) Strings(name string) []string {
+	if list, ok := params.lists[name]; ok {
+		return append(make([]string, 0, len(list)), list...)
+	}
+	if raw, ok := params.raw[name]; ok {
+		return []string{raw}
+	}
+	return []string{}
+}
+
+// Values returns every value of a repeated option, or of name if it has a single value.
+func (params *Params) Values(name string) []interface{} {
+	if _, ok := params.lists[name]; ok {
+		list := params.values[name].([]interface{})
+		return append(make([]interface{}, 0, len(list)), list...)
+	}
+	if value, ok := params.values[name]; ok {
+		return []interface{}{value}
+	}
+	return []interface{}{}
+}
+
+// Span returns where in the command string name was found.
+func (params *Params) Span(name string) (Span, bool) {
+	span, ok := params.spans[name]
+	return span, ok
+}
+
+// Map returns the raw text of every name, as passed to With handlers.
+func (params *Params) Map() map[string]string {
+	rtn := make(map[string]string, len(params.raw))
+	for key, value := range params.raw {
+		rtn[key] = value
+	}
+	return rtn
+}
@@ -0,0 +1,17 @@
+package cparser
+
+// ParseInfo describes how a command string was parsed.
+type ParseInfo struct {
+	// Factory is the factory that built the command.
+	Factory CommandFactory
+
+	// Syntax is the syntax of the factory, eg. "put [item] on [target]", or its type
+	// if it isn't a StandardCommandFactory.
+	Syntax string
+
+	// Params are the values matched by a StandardCommandFactory, or nil for other factories.
+	Params *Params
+
+	// Tokens is every token in the command string, and where it was found.
+	Tokens []Span
+}
@@ -0,0 +1,66 @@
+package cparser_test
+
+import (
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func TestParseStandardCommand(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+		playerId := 10
+
+		cmd, info, err := p.Parse("put  dragon on \"big table\"", playerId)
+		T.Assert(err == nil)
+		pcmd, ok := cmd.(*PutCommand)
+		T.Assert(ok)
+		T.Assert(pcmd.Item == "dragon")
+		T.Assert(pcmd.Target == "big table")
+
+		T.Assert(info.Syntax == "put [item] on [target]")
+		T.Assert(info.Factory != nil)
+		T.Assert(info.Params.String("item") == "dragon")
+		span, ok := info.Params.Span("target")
+		T.Assert(ok)
+		T.Assert(span.Value == "big table")
+		T.Assert(span.Start == 15)
+		T.Assert(span.End == 26)
+
+		T.Assert(len(info.Tokens) == 4)
+		T.Assert(info.Tokens[1].Start == 5)
+		T.Assert(info.Tokens[1].End == 11)
+	})
+}
+
+func TestParseCustomCommand(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+
+		cmd, info, err := p.Parse("go north", nil)
+		T.Assert(err == nil)
+		gcmd, ok := cmd.(*GoCommand)
+		T.Assert(ok)
+		T.Assert(!gcmd.Success)
+		_, ok = info.Factory.(*GoCommandFactory)
+		T.Assert(ok)
+		T.Assert(info.Params == nil)
+		T.Assert(info.Syntax == "*cparser_test.GoCommandFactory")
+	})
+}
+
+func TestParseErrors(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := fixture()
+
+		cmd, info, err := p.Parse("fly north", nil)
+		T.Assert(cmd == nil)
+		T.Assert(info == nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+
+		_, _, err = p.Parse("put", 1)
+		T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+	})
+}
@@ -0,0 +1,61 @@
+package cparser
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"ntoolkit/errors"
+)
+
+// Recovery is what a CommandParser does when a factory, middleware, handler or callback panics.
+type Recovery int
+
+const (
+	// RecoverPanics rejects the command with ErrCommandFailed, with a *PanicError as the inner error.
+	RecoverPanics Recovery = iota
+
+	// Repanic lets the panic continue, eg. so a test fails where the panic happened.
+	Repanic
+)
+
+// PanicError is a recovered panic, and the stack trace where it happened.
+type PanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", err.Value)
+}
+
+// SetRecovery sets what the parser does when a factory, middleware, handler or callback panics.
+// The default is RecoverPanics.
+func (p *CommandParser) SetRecovery(recovery Recovery) {
+	p.update(func(r *registry) {
+		r.recovery = recovery
+	})
+}
+
+// panicked returns the error for a recovered panic; call it from the deferred function,
+// so the stack trace includes where the panic happened.
+func panicked(value interface{}) error {
+	panicErr := &PanicError{Value: value, Stack: string(debug.Stack())}
+	return errors.Fail(ErrCommandFailed{}, panicErr, fmt.Sprintf("Command failed: %s", panicErr))
+}
+
+// repanic panics again with the original value if err is a recovered panic, and the parser doesn't recover panics.
+func (r *registry) repanic(err error) {
+	if r.recovery != Repanic {
+		return
+	}
+	for err != nil {
+		if panicErr, ok := err.(*PanicError); ok {
+			panic(panicErr.Value)
+		}
+		inner, ok := errors.Inner(err)
+		if !ok {
+			return
+		}
+		err = inner
+	}
+}
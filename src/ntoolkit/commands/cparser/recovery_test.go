@@ -0,0 +1,145 @@
+package cparser_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+	"ntoolkit/futures"
+	"ntoolkit/parser"
+)
+
+type PanicCommandFactory struct {
+}
+
+func (factory *PanicCommandFactory) Parse(tokenList *parser.Tokens, context interface{}) (commands.Command, error) {
+	if tokenList.Front != nil && tokenList.Front.CollectRaw(" ") == "crash" {
+		panic("factory crashed")
+	}
+	return nil, nil
+}
+
+type PanicCommand struct {
+	StallCommand
+}
+
+type PanicCommandHandler struct {
+}
+
+func (handler *PanicCommandHandler) Handles() reflect.Type {
+	return reflect.TypeOf(&PanicCommand{})
+}
+
+func (handler *PanicCommandHandler) Execute(command interface{}) *futures.Deferred {
+	panic(42)
+}
+
+func recoveryFixture() *cparser.CommandParser {
+	p := fixture()
+	p.Register(&PanicCommandFactory{})
+	p.Register(p.Command("explode").Handle(func(params *cparser.Params, context interface{}) (commands.Command, error) {
+		var player *aliasPlayer
+		return nil, errors.Fail(cparser.ErrBadSyntax{}, nil, player.name)
+	}))
+	p.Register(p.Command("fail").Handle(func(params *cparser.Params, context interface{}) (commands.Command, error) {
+		return &PanicCommand{}, nil
+	}))
+	p.Commands.Register(&PanicCommandHandler{})
+	return p
+}
+
+func findPanic(err error) *cparser.PanicError {
+	for err != nil {
+		if panicErr, ok := err.(*cparser.PanicError); ok {
+			return panicErr
+		}
+		err, _ = errors.Inner(err)
+	}
+	return nil
+}
+
+func TestRecoverFactoryPanic(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		_, err := recoveryFixture().Wait("crash", nil)
+		T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+		panicErr := findPanic(err)
+		T.Assert(panicErr != nil)
+		T.Assert(panicErr.Value == "factory crashed")
+		T.Assert(strings.Contains(panicErr.Stack, "PanicCommandFactory"))
+	})
+}
+
+func TestRecoverHandlerPanic(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := recoveryFixture()
+		_, err := p.Wait("explode", nil)
+		T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+		T.Assert(findPanic(err) != nil)
+
+		_, err = p.Wait("fail", nil)
+		T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+		T.Assert(findPanic(err).Value == 42)
+
+		_, _, err = p.Parse("explode", nil)
+		T.Assert(findPanic(err) != nil)
+	})
+}
+
+func TestRecoverCallbackPanic(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := recoveryFixture()
+		p.Use(func(next cparser.Handler) cparser.Handler {
+			return func(call *cparser.Call) *cparser.DeferredCommand {
+				if call.Stage != cparser.StageExecute {
+					return next(call)
+				}
+				return next(call).Then(func(cmd commands.Command) {
+					panic("callback crashed")
+				}, func(err error) {
+				})
+			}
+		})
+		_, err := p.Wait("go north", nil)
+		T.Assert(findPanic(err).Value == "callback crashed")
+	})
+}
+
+func TestRecoverCancelledCallbackPanic(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		panicking := make(chan error, 1)
+		stallFixture().ExecuteContext(ctx, "stall", nil).Then(func(cmd commands.Command) {
+		}, func(err error) {
+			panicking <- err
+			panic(err)
+		})
+		cancel()
+		T.Assert(errors.Is(<-panicking, cparser.ErrCancelled{}))
+
+		// An unrecovered panic on the watcher goroutine would have killed the test binary by now
+		time.Sleep(20 * time.Millisecond)
+	})
+}
+
+func TestRepanic(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		for _, command := range []string{"crash", "explode", "fail"} {
+			p := recoveryFixture()
+			p.SetRecovery(cparser.Repanic)
+			value := func() (value interface{}) {
+				defer (func() {
+					value = recover()
+				})()
+				p.Wait(command, nil)
+				return nil
+			}()
+			T.Assert(value != nil)
+		}
+	})
+}
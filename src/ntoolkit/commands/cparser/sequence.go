@@ -0,0 +1,222 @@
+package cparser
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"ntoolkit/commands"
+	"ntoolkit/errors"
+	"ntoolkit/futures"
+)
+
+// DefaultSeparators split a command string into a sequence of commands; "go north; look".
+var DefaultSeparators = []string{";", "then", "and then"}
+
+// Sequencer executes command strings that hold more than one command, in order.
+type Sequencer struct {
+	parser     *CommandParser
+	separators []string
+}
+
+// Sequence is the outcome of every command in a command string.
+type Sequence struct {
+	Raw   string
+	Steps []SequenceStep
+}
+
+// SequenceStep is the outcome of a single command in a Sequence.
+type SequenceStep struct {
+	// Raw is the command string for this step.
+	Raw string
+
+	// Executed is false for steps after the first one that failed.
+	Executed bool
+
+	// Command is the command, if it executed successfully.
+	Command commands.Command
+
+	// Err is the error the command was rejected with, if any.
+	Err error
+}
+
+// Err returns the error of the step that stopped the sequence, or nil if every step succeeded.
+func (seq *Sequence) Err() error {
+	for i := range seq.Steps {
+		if seq.Steps[i].Err != nil {
+			return seq.Steps[i].Err
+		}
+	}
+	return nil
+}
+
+// Sequencer returns a Sequencer that splits command strings on the given separators, or
+// DefaultSeparators. Separators made of words, like "then", only split on whole tokens;
+// others, like ";", split anywhere. Neither splits inside a quoted block.
+func (p *CommandParser) Sequencer(separators ...string) *Sequencer {
+	if len(separators) == 0 {
+		separators = DefaultSeparators
+	}
+	return &Sequencer{parser: p, separators: separators}
+}
+
+// Split returns the commands in a command string.
+func (s *Sequencer) Split(command string) ([]string, error) {
+	tokens, spans, err := s.parser.tokenize(command)
+	if err != nil {
+		return nil, errors.Fail(ErrBadSyntax{}, err, "Invalid command string")
+	}
+	r := s.parser.registry()
+	input := &Input{Raw: command, Tokens: tokens, Policy: &r.policy, spans: spans}
+	cuts := s.cuts(input, input.tokens())
+	rtn := make([]string, 0, len(cuts)+1)
+	start := 0
+	for _, cut := range cuts {
+		if cut[0] < start {
+			continue
+		}
+		rtn = appendPart(rtn, command[start:cut[0]])
+		start = cut[1]
+	}
+	return appendPart(rtn, command[start:]), nil
+}
+
+// cuts returns the start and end of every separator in the input, in order.
+func (s *Sequencer) cuts(input *Input, tokens []Span) [][2]int {
+	quoted := make([]Span, 0)
+	for _, token := range tokens {
+		if input.quoted(token) {
+			quoted = append(quoted, token)
+		}
+	}
+	rtn := make([][2]int, 0)
+	for _, separator := range s.separators {
+		words := strings.Fields(separator)
+		if len(words) == 0 {
+			continue
+		}
+		if strings.IndexFunc(separator, isSeparatorSymbol) >= 0 {
+			for offset := 0; offset < len(input.Raw); {
+				found := strings.Index(input.Raw[offset:], separator)
+				if found < 0 {
+					break
+				}
+				start := offset + found
+				if !insideSpans(quoted, start) {
+					rtn = append(rtn, [2]int{start, start + len(separator)})
+				}
+				offset = start + len(separator)
+			}
+			continue
+		}
+		for i := 0; i+len(words) <= len(tokens); i++ {
+			if matchWords(input, tokens[i:i+len(words)], words) {
+				rtn = append(rtn, [2]int{tokens[i].Start, tokens[i+len(words)-1].End})
+			}
+		}
+	}
+	sort.SliceStable(rtn, func(i, j int) bool {
+		if rtn[i][0] != rtn[j][0] {
+			return rtn[i][0] < rtn[j][0]
+		}
+		return rtn[i][1] > rtn[j][1]
+	})
+	return rtn
+}
+
+// Execute splits a command string and executes each command in order, stopping at the
+// first one that is rejected. The DeferredSequence resolves with the outcome of every
+// step, even if one failed; use Sequence.Err(). It is only rejected if the command
+// string can't be split.
+func (s *Sequencer) Execute(command string, userContext interface{}) *DeferredSequence {
+	return s.ExecuteContext(context.Background(), command, userContext)
+}
+
+// ExecuteContext is the same as Execute, but executes each command with ExecuteContext.
+func (s *Sequencer) ExecuteContext(ctx context.Context, command string, userContext interface{}) *DeferredSequence {
+	rtn := &DeferredSequence{DeferredValue: &futures.DeferredValue{}}
+	parts, err := s.Split(command)
+	if err != nil {
+		rtn.Reject(err)
+		return rtn
+	}
+	seq := &Sequence{Raw: command, Steps: make([]SequenceStep, len(parts))}
+	for i := range parts {
+		seq.Steps[i].Raw = parts[i]
+	}
+	var step func(i int)
+	step = func(i int) {
+		if i >= len(seq.Steps) {
+			rtn.Resolve(seq)
+			return
+		}
+		seq.Steps[i].Executed = true
+		s.parser.ExecuteContext(ctx, seq.Steps[i].Raw, userContext).Then(func(cmd commands.Command) {
+			seq.Steps[i].Command = cmd
+			step(i + 1)
+		}, func(err error) {
+			seq.Steps[i].Err = err
+			rtn.Resolve(seq)
+		})
+	}
+	step(0)
+	return rtn
+}
+
+// Wait executes a command string and returns the outcome, and the error that stopped it, if any.
+func (s *Sequencer) Wait(command string, userContext interface{}) (*Sequence, error) {
+	return s.WaitContext(context.Background(), command, userContext)
+}
+
+// WaitContext is the same as Wait, but executes each command with ExecuteContext.
+func (s *Sequencer) WaitContext(ctx context.Context, command string, userContext interface{}) (*Sequence, error) {
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	var err error
+	var seq *Sequence
+	s.ExecuteContext(ctx, command, userContext).Then(func(result *Sequence) {
+		seq = result
+		err = result.Err()
+		wg.Done()
+	}, func(errRtn error) {
+		err = errRtn
+		wg.Done()
+	})
+	wg.Wait()
+	return seq, err
+}
+
+// matchWords returns true if the tokens are unquoted, and match the words of a separator.
+func matchWords(input *Input, tokens []Span, words []string) bool {
+	for i := range words {
+		if tokens[i].Start < 0 || input.quoted(tokens[i]) || !input.Policy.Match(tokens[i].Value, words[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// insideSpans returns true if the offset is inside any of the spans.
+func insideSpans(spans []Span, offset int) bool {
+	for _, span := range spans {
+		if offset >= span.Start && offset < span.End {
+			return true
+		}
+	}
+	return false
+}
+
+// appendPart appends a part of a command string, unless it is blank.
+func appendPart(parts []string, part string) []string {
+	part = strings.TrimSpace(part)
+	if part == "" {
+		return parts
+	}
+	return append(parts, part)
+}
+
+func isSeparatorSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
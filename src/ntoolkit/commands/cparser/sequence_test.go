@@ -0,0 +1,61 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func TestSequencerSplit(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		s := fixture().Sequencer()
+		split := func(command string) string {
+			parts, err := s.Split(command)
+			T.Assert(err == nil)
+			return strings.Join(parts, "|")
+		}
+		T.Assert(split("go north; look") == "go north|look")
+		T.Assert(split("go north;look;") == "go north|look")
+		T.Assert(split("take sword then wield sword") == "take sword|wield sword")
+		T.Assert(split("go north and then look, then go south") == "go north|look,|go south")
+		T.Assert(split("say \"north; then south\"; look") == "say \"north; then south\"|look")
+		T.Assert(split("look") == "look")
+		T.Assert(split("") == "")
+	})
+}
+
+func TestSequencerCustomSeparators(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		s := fixture().Sequencer("&&")
+		parts, err := s.Split("go north && look then go south")
+		T.Assert(err == nil)
+		T.Assert(len(parts) == 2)
+		T.Assert(parts[1] == "look then go south")
+	})
+}
+
+func TestSequencerExecute(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		seq, err := fixture().Sequencer().Wait("go north; look south then go east", nil)
+		T.Assert(err == nil)
+		T.Assert(len(seq.Steps) == 3)
+		T.Assert(seq.Steps[0].Command.(*GoCommand).Direction == "north")
+		T.Assert(seq.Steps[1].Command.(*LookCommand).Direction == "south")
+		T.Assert(seq.Steps[2].Executed)
+	})
+}
+
+func TestSequencerStopsOnFailure(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		seq, err := fixture().Sequencer().Wait("go north; dance; look south", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+		T.Assert(len(seq.Steps) == 3)
+		T.Assert(seq.Steps[0].Executed && seq.Steps[0].Err == nil)
+		T.Assert(seq.Steps[1].Executed && seq.Steps[1].Err == err)
+		T.Assert(!seq.Steps[2].Executed)
+		T.Assert(seq.Steps[2].Command == nil)
+	})
+}
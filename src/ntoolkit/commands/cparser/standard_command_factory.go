@@ -22,9 +22,21 @@ type standardCommandWord struct {
 	// The name of this word
 	Name string
 
+	// Accepted spellings of a word, if it has more than one; Name is always the first.
+	Alternatives []string
+
 	// If unique, matching this token and not all others generates a syntax error.
 	// For example, if you want 'go home now' to be an error, make word 'go' unique.
 	Unique bool
+
+	// If optional, the command still matches when this word is missing.
+	Optional bool
+
+	// If greedy, this token takes as many tokens as it can, rather than just one.
+	Greedy bool
+
+	// If set, the value of this token is converted to this type.
+	Kind TokenType
 }
 
 // StandardCommandFactory is a CommandFactory for a command in the form
@@ -35,13 +47,35 @@ type StandardCommandFactory struct {
 	// List of items that work
 	items []standardCommandWord
 
+	// Flags, which can be anywhere in the input, and if the most recently added thing was a flag.
+	flags    []standardCommandFlag
+	lastFlag bool
+
+	// If set, used instead of the match policy of the input.
+	policy *MatchPolicy
+
+	// If loose, tokens left over after the last item are ignored.
+	loose bool
+
+	// Explicit priority used by DispatchSpecific.
+	priority int
+
+	// The first problem with the syntax given to Command(), if any; eg. an unknown token type.
+	invalid string
+
+	// Documentation for the help system.
+	info CommandInfo
+
+	// Completion values for tokens, by name.
+	providers map[string]CandidateProvider
+
 	// Invoked after successful parse check to generate a command.
-	handler func(params map[string]string, context interface{}) (commands.Command, error)
+	handler func(params *Params, context interface{}) (commands.Command, error)
 }
 
 // newStandardCommandFactory creates an returns a command factory
 func newStandardCommandFactory() *StandardCommandFactory {
-	return &StandardCommandFactory{items: make([]standardCommandWord, 0)}
+	return &StandardCommandFactory{items: make([]standardCommandWord, 0), providers: make(map[string]CandidateProvider)}
 }
 
 // Word adds a word to the command syntax, and returns the instance.
@@ -54,6 +88,22 @@ func (factory *StandardCommandFactory) Word(word string, isUnique ...bool) *Stan
 		Type:   standardCommandTypeWord,
 		Name:   word,
 		Unique: isWordUnique})
+	factory.lastFlag = false
+	return factory
+}
+
+// Words adds a word with alternative spellings to the command syntax, and returns the instance.
+// The alternative that matched is stored in the params map under the first word.
+func (factory *StandardCommandFactory) Words(words ...string) *StandardCommandFactory {
+	if len(words) == 0 {
+		return factory
+	}
+	factory.items = append(factory.items, standardCommandWord{
+		Type:         standardCommandTypeWord,
+		Name:         words[0],
+		Alternatives: words,
+		Unique:       false})
+	factory.lastFlag = false
 	return factory
 }
 
@@ -63,80 +113,438 @@ func (factory *StandardCommandFactory) Token(tokenName string) *StandardCommandF
 		Type:   standardCommandTypeToken,
 		Name:   tokenName,
 		Unique: false})
+	factory.lastFlag = false
+	return factory
+}
+
+// Optional marks the most recently added word or token as optional, and returns the instance.
+// Optional items that are present appear in the params map; missing ones do not.
+func (factory *StandardCommandFactory) Optional() *StandardCommandFactory {
+	if len(factory.items) > 0 {
+		factory.items[len(factory.items)-1].Optional = true
+	}
+	return factory
+}
+
+// Greedy marks the most recently added token as greedy, and returns the instance.
+// A greedy token takes the rest of the input (or as much of it as it can while still
+// matching any words that follow it), keeping the original spacing of the command string.
+func (factory *StandardCommandFactory) Greedy() *StandardCommandFactory {
+	if len(factory.items) > 0 && factory.items[len(factory.items)-1].Type == standardCommandTypeToken {
+		factory.items[len(factory.items)-1].Greedy = true
+	}
+	return factory
+}
+
+// As sets the type of the most recently added token or option, and returns the instance.
+// If the value of the token can't be converted Parse raises ErrBadSyntax.
+func (factory *StandardCommandFactory) As(kind TokenType) *StandardCommandFactory {
+	if factory.lastFlag {
+		factory.flags[len(factory.flags)-1].Kind = kind
+	} else if len(factory.items) > 0 && factory.items[len(factory.items)-1].Type == standardCommandTypeToken {
+		factory.items[len(factory.items)-1].Kind = kind
+	}
+	return factory
+}
+
+// Policy sets the match policy for the words in this factory, instead of using the
+// policy of the CommandParser, and returns the instance.
+func (factory *StandardCommandFactory) Policy(policy MatchPolicy) *StandardCommandFactory {
+	factory.policy = &policy
+	return factory
+}
+
+// Strict sets if tokens left over after the syntax has matched are an error, and returns
+// the instance. Factories are strict by default; if the syntax matched except for the
+// trailing tokens it is not a match, or a syntax error if a unique word matched.
+func (factory *StandardCommandFactory) Strict(strict bool) *StandardCommandFactory {
+	factory.loose = !strict
+	return factory
+}
+
+// fail records a problem with the syntax of this factory, unless it already has one.
+func (factory *StandardCommandFactory) fail(problem string) {
+	if factory.invalid == "" {
+		factory.invalid = problem
+	}
+}
+
+// Priority sets the priority of this factory when the parser uses DispatchSpecific, and
+// returns the instance. A higher priority wins over more literal words or typed tokens.
+func (factory *StandardCommandFactory) Priority(priority int) *StandardCommandFactory {
+	factory.priority = priority
+	return factory
+}
+
+// Summary sets the one line description of this command for the help system, and returns the instance.
+func (factory *StandardCommandFactory) Summary(summary string) *StandardCommandFactory {
+	factory.info.Summary = summary
+	return factory
+}
+
+// Description sets the long description of this command for the help system, and returns the instance.
+func (factory *StandardCommandFactory) Description(description string) *StandardCommandFactory {
+	factory.info.Description = description
+	return factory
+}
+
+// Example adds examples of this command for the help system, and returns the instance.
+func (factory *StandardCommandFactory) Example(examples ...string) *StandardCommandFactory {
+	factory.info.Examples = append(factory.info.Examples, examples...)
+	return factory
+}
+
+// Category sets the category of this command for the help system, and returns the instance.
+func (factory *StandardCommandFactory) Category(category string) *StandardCommandFactory {
+	factory.info.Category = category
+	return factory
+}
+
+// Info returns the documentation for this command.
+func (factory *StandardCommandFactory) Info() CommandInfo {
+	return factory.info
+}
+
+// Candidates sets the provider of tab completion values for a token, and returns the instance.
+func (factory *StandardCommandFactory) Candidates(token string, provider CandidateProvider) *StandardCommandFactory {
+	factory.providers[token] = provider
 	return factory
 }
 
 // With sets the handler to generate a command on the factory
 func (factory *StandardCommandFactory) With(factoryFunc func(params map[string]string, context interface{}) (commands.Command, error)) *StandardCommandFactory {
+	factory.handler = func(params *Params, context interface{}) (commands.Command, error) {
+		return factoryFunc(params.Map(), context)
+	}
+	return factory
+}
+
+// Handle sets the handler to generate a command on the factory; it is the same as With,
+// but the handler gets typed values for typed tokens.
+func (factory *StandardCommandFactory) Handle(factoryFunc func(params *Params, context interface{}) (commands.Command, error)) *StandardCommandFactory {
 	factory.handler = factoryFunc
 	return factory
 }
 
 // String renders the factory as a string list
 func (factory *StandardCommandFactory) String() string {
-	buffer := make([]string, len(factory.items))
+	buffer := make([]string, len(factory.items), len(factory.items)+len(factory.flags))
 	for i := range factory.items {
 		item := factory.items[i]
-		if item.Type == standardCommandTypeWord {
-			buffer[i] = item.Name
-		} else if item.Type == standardCommandTypeToken {
-			buffer[i] = fmt.Sprintf("[%s]", item.Name)
+		buffer[i] = item.String()
+		if item.Optional {
+			buffer[i] = "?" + buffer[i]
 		}
 	}
+	for i := range factory.flags {
+		buffer = append(buffer, factory.flags[i].String())
+	}
 	return strings.Join(buffer, " ")
 }
 
+// String renders a single item; "on|onto", "[target]", "[count:int]"
+func (item *standardCommandWord) String() string {
+	if item.Type == standardCommandTypeWord && len(item.Alternatives) > 0 {
+		return strings.Join(item.Alternatives, "|")
+	} else if item.Type == standardCommandTypeWord {
+		return item.Name
+	}
+	rtn := item.Name
+	if item.Kind != nil {
+		rtn = fmt.Sprintf("%s:%s", item.Name, item.Kind.Name())
+	}
+	if item.Greedy {
+		rtn += "..."
+	}
+	return fmt.Sprintf("[%s]", rtn)
+}
+
 // Parse checks the token list against the defined syntax and raises and error if it doesn't work.
 // Notice that
-func (factory *StandardCommandFactory) Parse(tokenList *parser.Tokens, context interface{}) (cmd commands.Command, err error) {
+func (factory *StandardCommandFactory) Parse(tokenList *parser.Tokens, context interface{}) (commands.Command, error) {
+	return factory.ParseInput(&Input{Tokens: tokenList, Context: context})
+}
+
+// ParseInput is the same as Parse, but greedy tokens keep the spacing of the raw input.
+func (factory *StandardCommandFactory) ParseInput(input *Input) (commands.Command, error) {
+	cmd, _, err := factory.parse(input)
+	return cmd, err
+}
+
+// parse is ParseInput, but also returns the params that matched.
+func (factory *StandardCommandFactory) parse(input *Input) (cmd commands.Command, params *Params, err error) {
 	defer (func() {
-		r := recover()
-		if r != nil {
-			err = r.(error)
+		if value := recover(); value != nil {
+			err = panicked(value)
 			cmd = nil
 		}
 	})()
 
 	// setup
-	params := make(map[string]string)
-	foundUnique := false
-	foundMatch := 0
-	marker := tokenList.Front
-
-	// collect values
-	for offset := 0; offset < len(factory.items) && marker != nil; offset++ {
-		raw := marker.CollectRaw(" ")
-		item := factory.items[offset]
+	state := factory.newState(input)
+	params = state.params
+
+	// validate; error if we didn't match but we found any unique tokens,
+	// or if we only failed to match because a typed token had a bad value.
+	// Bad flags are only an error if this handler is otherwise the right one.
+	// If we found no match, this handler isn't the right one.
+	matched := factory.match(state, 0, 0)
+	if state.flagErr != nil && (matched || state.foundUnique) {
+		return nil, nil, errors.Fail(ErrBadSyntax{}, state.flagErr, state.flagErr.Message)
+	}
+	if !matched {
+		if syntaxErr := factory.syntaxError(state); syntaxErr != nil {
+			return nil, nil, errors.Fail(ErrBadSyntax{}, syntaxErr, syntaxErr.Message)
+		}
+		return nil, nil, nil
+	}
+
+	// ! Someone used a bad syntax in Command()
+	if factory.invalid != "" {
+		return nil, nil, errors.Fail(ErrInvalidFactory{}, nil, fmt.Sprintf("Invalid command %s: %s", factory, factory.invalid))
+	}
+
+	// ! Someone forget to call With()
+	if factory.handler == nil {
+		return nil, nil, errors.Fail(ErrBadSyntax{}, nil, "No handler attached to standard command factory")
+	}
+
+	// Try to get a command back
+	cmd, err = factory.handler(params, input.Context)
+	return cmd, params, err
+}
+
+// topic returns the help topic of this factory; its first word, or its syntax if it has none.
+func (factory *StandardCommandFactory) topic() string {
+	if len(factory.items) > 0 && factory.items[0].Type == standardCommandTypeWord {
+		return factory.items[0].Name
+	}
+	return factory.String()
+}
+
+// Rank returns how specifically the input matches this factory, for DispatchSpecific.
+func (factory *StandardCommandFactory) Rank(input *Input) (Specificity, bool) {
+	state := factory.newState(input)
+	if !factory.match(state, 0, 0) {
+		return Specificity{}, false
+	}
+	return Specificity{Priority: factory.priority, Words: state.words, Typed: state.typed, Tokens: state.consumed}, true
+}
+
+// newState returns the initial state to match the input against this factory.
+func (factory *StandardCommandFactory) newState(input *Input) *standardCommandState {
+	state := &standardCommandState{input: input, tokens: input.tokens(), params: newParams(), policy: input.Policy, trailing: -1, furthest: -1}
+	state.params.ctx = input.Ctx
+	if factory.policy != nil {
+		state.policy = factory.policy
+	}
+	if len(factory.flags) > 0 {
+		flags := factory.splitFlags(input, state.tokens)
+		state.tokens = flags.positional
+		state.origin = flags.origin
+		state.flagErr = flags.err
+		if state.flagErr == nil {
+			state.flagErr = factory.setFlags(input, flags, state.policy, state.params)
+		}
+	}
+	return state
+}
+
+// matches checks if a raw token is this word, or any of its alternatives.
+func (item *standardCommandWord) matches(policy *MatchPolicy, raw string) bool {
+	_, ok := item.spelling(policy, raw)
+	return ok
+}
+
+// spelling returns the spelling in the syntax of the word or alternative a raw token matches.
+func (item *standardCommandWord) spelling(policy *MatchPolicy, raw string) (string, bool) {
+	if len(item.Alternatives) == 0 {
+		return item.Name, policy.Match(raw, item.Name)
+	}
+	for _, alternative := range item.Alternatives {
+		if policy.Match(raw, alternative) {
+			return alternative, true
+		}
+	}
+	return "", false
+}
+
+// standardCommandInvalidValue is a typed token that failed to convert.
+type standardCommandInvalidValue struct {
+	item  *standardCommandWord
+	raw   string
+	err   error
+	token int
+	span  Span
+}
+
+// standardCommandState is the working state of a single Parse call.
+type standardCommandState struct {
+	input       *Input
+	tokens      []Span
+	params      *Params
+	policy      *MatchPolicy
+	foundUnique bool
+
+	// If the factory has flags, tokens are only the positional tokens, and origin is the
+	// index in the input of each of them, and then the number of tokens in the input.
+	origin []int
+
+	// The first invalid flag, if any.
+	flagErr *SyntaxError
+
+	// Typed tokens on the current path that failed to convert.
+	invalid []standardCommandInvalidValue
+
+	// The first path that would have matched, except for an invalid typed token.
+	invalidValue *standardCommandInvalidValue
+
+	// The offset of the leftover tokens of the first path that would have matched
+	// except for them, or -1.
+	trailing int
+
+	// The furthest token any path reached before failing, and the items expected there.
+	furthest int
+	expected []string
+
+	// The number of words and typed tokens on the current path.
+	words int
+	typed int
+
+	// The number of tokens used by the path that matched.
+	consumed int
+}
+
+// match recursively checks items from offset against tokens from marker.
+// Optional items are first tried as present and then skipped, so when the
+// input is ambiguous the earliest optional items are filled first.
+func (factory *StandardCommandFactory) match(state *standardCommandState, offset int, marker int) bool {
+	if offset == len(factory.items) {
+		if marker < len(state.tokens) && !factory.loose {
+			if state.trailing < 0 {
+				state.trailing = marker
+			}
+			return false
+		}
+		if len(state.invalid) > 0 {
+			if state.invalidValue == nil {
+				invalid := state.invalid[0]
+				state.invalidValue = &invalid
+			}
+			return false
+		}
+		state.consumed = marker
+		return true
+	}
+	item := &factory.items[offset]
+	if marker < len(state.tokens) {
+		raw := state.tokens[marker].Value
 		if item.Type == standardCommandTypeWord {
-			// TODO: Capitialization check?
-			if raw == item.Name {
-				foundMatch += 1
+			if spelling, ok := item.spelling(state.policy, raw); ok {
 				if item.Unique {
-					foundUnique = true
+					state.foundUnique = true
+				}
+				if item.Optional || len(item.Alternatives) > 0 {
+					span := state.tokens[marker]
+					span.Value = spelling
+					state.params.set(item.Name, span, spelling)
+				}
+				state.words++
+				if factory.match(state, offset+1, marker+1) {
+					return true
+				}
+				state.words--
+				state.params.unset(item.Name)
+			} else {
+				state.expect(item, marker)
+			}
+		} else if item.Type == standardCommandTypeToken && item.Greedy {
+			for end := len(state.tokens); end > marker; end-- {
+				if factory.matchToken(state, item, state.cover(marker, end), offset, marker, end) {
+					return true
 				}
 			}
 		} else if item.Type == standardCommandTypeToken {
-			params[item.Name] = raw
-			foundMatch += 1
+			if factory.matchToken(state, item, state.tokens[marker], offset, marker, marker+1) {
+				return true
+			}
 		}
-		marker = marker.Next
+	} else {
+		state.expect(item, marker)
 	}
+	if item.Optional {
+		return factory.match(state, offset+1, marker)
+	}
+	return false
+}
 
-	// validate; error if not right length but we found any unique tokens
-	// If we found no match, this handler isn't the right one.
-	if foundMatch != len(factory.items) {
-		if foundUnique {
-			return nil, errors.Fail(ErrBadSyntax{}, nil, fmt.Sprintf("Invalid syntax for command, did not match: %s", factory))
-		} else {
-			return nil, nil
-		}
+// index returns the index in the input of a token, which may be len(state.tokens) for the end of the input.
+func (state *standardCommandState) index(token int) int {
+	if state.origin == nil || token < 0 || token >= len(state.origin) {
+		return token
 	}
+	return state.origin[token]
+}
 
-	// ! Someone forget to call With()
-	if factory.handler == nil {
-		return nil, errors.Fail(ErrBadSyntax{}, nil, "No handler attached to standard command factory")
+// cover returns the span covering the tokens from first up to (not including) last; if flags
+// were taken from between them, the value is the tokens joined with a space. A single token
+// is its value, without any quotes, as it is for a token that isn't greedy.
+func (state *standardCommandState) cover(first int, last int) Span {
+	if last == first+1 {
+		return state.tokens[first]
 	}
+	if first >= last || state.index(last-1)-state.index(first) == last-1-first {
+		return state.input.cover(state.tokens, first, last)
+	}
+	buffer := make([]string, 0, last-first)
+	for i := first; i < last; i++ {
+		buffer = append(buffer, state.tokens[i].Value)
+	}
+	rtn := state.input.cover(state.tokens, first, last)
+	rtn.Value = strings.Join(buffer, " ")
+	return rtn
+}
 
-	// Try to get a command back
-	return factory.handler(params, context)
+// expect records that an item didn't match the token at marker, for syntax errors.
+func (state *standardCommandState) expect(item *standardCommandWord, marker int) {
+	if marker > state.furthest {
+		state.furthest = marker
+		state.expected = make([]string, 0)
+	}
+	if marker < state.furthest {
+		return
+	}
+	description := item.String()
+	for i := range state.expected {
+		if state.expected[i] == description {
+			return
+		}
+	}
+	state.expected = append(state.expected, description)
+}
+
+// matchToken assigns the span, which starts at token first, to the token item and continues matching from marker.
+func (factory *StandardCommandFactory) matchToken(state *standardCommandState, item *standardCommandWord, span Span, offset int, first int, marker int) bool {
+	raw := span.Value
+	var value interface{} = raw
+	typed := 0
+	if item.Kind != nil {
+		converted, err := convert(item.Kind, state.policy, raw)
+		if err != nil {
+			state.invalid = append(state.invalid, standardCommandInvalidValue{item: item, raw: raw, err: err, token: first, span: span})
+			factory.match(state, offset+1, marker)
+			state.invalid = state.invalid[:len(state.invalid)-1]
+			return false
+		}
+		value = converted
+		typed = 1
+	}
+	state.params.set(item.Name, span, value)
+	state.typed += typed
+	if factory.match(state, offset+1, marker) {
+		return true
+	}
+	state.typed -= typed
+	state.params.unset(item.Name)
+	return false
 }
@@ -0,0 +1,416 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+	"ntoolkit/parser/tools"
+)
+
+func parseWith(factory *cparser.StandardCommandFactory, command string) (map[string]string, error) {
+	var params map[string]string
+	factory.With(func(p map[string]string, context interface{}) (commands.Command, error) {
+		params = p
+		return &GoCommand{}, nil
+	})
+	blocks := tools.NewBlockParser()
+	blocks.Parse(command)
+	tokens, err := blocks.Finished()
+	if err != nil {
+		return nil, err
+	}
+	_, err = factory.Parse(tokens, nil)
+	return params, err
+}
+
+func TestOptionalWords(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command("put", "[item]", "on", "[target]", "?quietly")
+		T.Assert(factory.String() == "put [item] on [target] ?quietly")
+
+		params, err := parseWith(factory, "put sword on table")
+		T.Assert(err == nil)
+		T.Assert(params["item"] == "sword")
+		T.Assert(params["target"] == "table")
+		_, found := params["quietly"]
+		T.Assert(!found)
+
+		params, err = parseWith(factory, "put sword on table quietly")
+		T.Assert(err == nil)
+		T.Assert(params["target"] == "table")
+		T.Assert(params["quietly"] == "quietly")
+
+		params, err = parseWith(factory, "put sword under table")
+		T.Assert(err == nil)
+		T.Assert(params == nil)
+	})
+}
+
+func TestOptionalTokens(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command().Word("look").Token("direction").Optional().Word("carefully").Optional()
+
+		params, err := parseWith(factory, "look")
+		T.Assert(err == nil)
+		T.Assert(params != nil)
+		_, found := params["direction"]
+		T.Assert(!found)
+
+		params, err = parseWith(factory, "look north")
+		T.Assert(err == nil)
+		T.Assert(params["direction"] == "north")
+
+		params, err = parseWith(factory, "look north carefully")
+		T.Assert(err == nil)
+		T.Assert(params["direction"] == "north")
+		T.Assert(params["carefully"] == "carefully")
+	})
+}
+
+func TestOptionalGapBeforeWord(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command("give", "[item]", "?[count]", "to", "[target]")
+
+		params, err := parseWith(factory, "give coins 10 to bob")
+		T.Assert(err == nil)
+		T.Assert(params["count"] == "10")
+		T.Assert(params["target"] == "bob")
+
+		params, err = parseWith(factory, "give coins to bob")
+		T.Assert(err == nil)
+		T.Assert(params["item"] == "coins")
+		T.Assert(params["target"] == "bob")
+		_, found := params["count"]
+		T.Assert(!found)
+	})
+}
+
+func TestWordAlternatives(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command("put|place", "[item]", "on|onto|upon", "[target]")
+		T.Assert(factory.String() == "put|place [item] on|onto|upon [target]")
+
+		params, err := parseWith(factory, "put sword on table")
+		T.Assert(err == nil)
+		T.Assert(params["put"] == "put")
+		T.Assert(params["on"] == "on")
+
+		params, err = parseWith(factory, "place sword upon table")
+		T.Assert(err == nil)
+		T.Assert(params["put"] == "place")
+		T.Assert(params["on"] == "upon")
+		T.Assert(params["item"] == "sword")
+		T.Assert(params["target"] == "table")
+
+		params, err = parseWith(factory, "place sword under table")
+		T.Assert(err == nil)
+		T.Assert(params == nil)
+	})
+}
+
+func TestWordAlternativesBuilder(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command().Word("put").Token("item").Words("in", "into").Token("container")
+
+		params, err := parseWith(factory, "put coin into box")
+		T.Assert(err == nil)
+		T.Assert(params["in"] == "into")
+		T.Assert(params["container"] == "box")
+	})
+}
+
+func TestGreedyToken(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command("say", "[message...]")
+		T.Assert(factory.String() == "say [message...]")
+
+		params, err := parseWith(factory, "say hello there everyone")
+		T.Assert(err == nil)
+		T.Assert(params["message"] == "hello there everyone")
+
+		params, err = parseWith(factory, "say")
+		T.Assert(err == nil)
+		T.Assert(params == nil)
+	})
+}
+
+func TestGreedyTokenFollowedByWord(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command().Word("mail").Token("subject").Greedy().Word("to").Token("target")
+
+		params, err := parseWith(factory, "mail the big news to bob")
+		T.Assert(err == nil)
+		T.Assert(params["subject"] == "the big news")
+		T.Assert(params["target"] == "bob")
+	})
+}
+
+func TestGreedyTokenKeepsSpacing(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		var message string
+		p := cparser.New()
+		p.Register(p.Command("say", "[message...]").With(func(params map[string]string, context interface{}) (commands.Command, error) {
+			message = params["message"]
+			return &GoCommand{}, nil
+		}))
+		p.Commands.Register(&GoCommandHandler{})
+
+		_, err := p.Wait("say  hello    \"there  you\" all ", nil)
+		T.Assert(err == nil)
+		T.Assert(message == "hello    \"there  you\" all")
+	})
+}
+
+func TestGreedyTokenQuoted(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		var message string
+		p := cparser.New()
+		p.Register(p.Command("say", "[message...]").With(func(params map[string]string, context interface{}) (commands.Command, error) {
+			message = params["message"]
+			return &GoCommand{}, nil
+		}))
+		p.Commands.Register(&GoCommandHandler{})
+
+		_, err := p.Wait("say \"hello world\"", nil)
+		T.Assert(err == nil)
+		T.Assert(message == "hello world")
+
+		_, err = p.Wait("say \"hello\" world", nil)
+		T.Assert(err == nil)
+		T.Assert(message == "\"hello\" world")
+	})
+}
+
+func TestTypedTokens(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		var params *cparser.Params
+		p := cparser.New()
+		factory := p.Command("wait", "[count:int]", "[amount:float]", "[dir:enum(north,south)]", "[flag:bool]", "[wait:duration]")
+		factory.Handle(func(p *cparser.Params, context interface{}) (commands.Command, error) {
+			params = p
+			return &GoCommand{}, nil
+		})
+		T.Assert(factory.String() == "wait [count:int] [amount:float] [dir:enum(north,south)] [flag:bool] [wait:duration]")
+		p.Register(factory)
+		p.Commands.Register(&GoCommandHandler{})
+
+		_, err := p.Wait("wait 3 1.5 north yes 2m", nil)
+		T.Assert(err == nil)
+		T.Assert(params.Int("count") == 3)
+		T.Assert(params.Float("amount") == 1.5)
+		T.Assert(params.String("dir") == "north")
+		T.Assert(params.Bool("flag"))
+		T.Assert(params.Duration("wait") == 2*time.Minute)
+		T.Assert(params.String("count") == "3")
+		T.Assert(params.Map()["wait"] == "2m")
+	})
+}
+
+func TestTypedTokenInvalidValue(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command().Word("go").Token("dir").As(cparser.TypeEnum("north", "south"))
+
+		_, err := parseWith(factory, "go up")
+		T.Assert(err != nil)
+		T.Assert(errors.Is(err, cparser.ErrBadSyntax{}))
+		T.Assert(strings.Contains(err.Error(), "[dir]"))
+		T.Assert(strings.Contains(err.Error(), "enum(north,south)"))
+
+		params, err := parseWith(factory, "go south")
+		T.Assert(err == nil)
+		T.Assert(params["dir"] == "south")
+
+		params, err = parseWith(factory, "run south")
+		T.Assert(err == nil)
+		T.Assert(params == nil)
+	})
+}
+
+func TestTypedTokenBacktracks(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		factory := p.Command("give", "[item]", "?[count:int]", "to", "[target]")
+
+		params, err := parseWith(factory, "give coins 10 to bob")
+		T.Assert(err == nil)
+		T.Assert(params["count"] == "10")
+
+		params, err = parseWith(factory, "give coins to bob")
+		T.Assert(err == nil)
+		T.Assert(params["target"] == "bob")
+	})
+}
+
+type tokenTypeItem struct{}
+
+func (t *tokenTypeItem) Name() string {
+	return "item"
+}
+
+func (t *tokenTypeItem) Convert(raw string) (interface{}, error) {
+	return strings.ToUpper(raw), nil
+}
+
+func TestCustomTokenType(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.RegisterType(&tokenTypeItem{})
+		factory := p.Command("take", "[thing:item]")
+		T.Assert(factory.String() == "take [thing:item]")
+
+		var value interface{}
+		factory.Handle(func(params *cparser.Params, context interface{}) (commands.Command, error) {
+			value = params.Value("thing")
+			return &GoCommand{}, nil
+		})
+		p.Register(factory)
+		p.Commands.Register(&GoCommandHandler{})
+
+		_, err := p.Wait("take sword", nil)
+		T.Assert(err == nil)
+		T.Assert(value == "SWORD")
+	})
+}
+
+func TestMatchPolicy(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		var params map[string]string
+		handler := func(p map[string]string, context interface{}) (commands.Command, error) {
+			params = p
+			return &GoCommand{}, nil
+		}
+
+		p := cparser.New()
+		p.Register(p.Command("go", "[dir]").With(handler))
+		p.Commands.Register(&GoCommandHandler{})
+
+		_, err := p.Wait("Go North", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+
+		p.SetPolicy(cparser.LooseMatch)
+		_, err = p.Wait("Go North", nil)
+		T.Assert(err == nil)
+		T.Assert(params["dir"] == "North")
+
+		_, err = p.Wait("go! north", nil)
+		T.Assert(err == nil)
+	})
+}
+
+func TestMatchPolicyAlternatives(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.SetPolicy(cparser.LooseMatch)
+		p.Register(p.Command("put", "[item]", "on|onto", "[target]", "?quietly").With(lintHandler))
+
+		_, info, err := p.Parse("PUT sword ONTO! table Quietly", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.Map()["on"] == "onto")
+		T.Assert(info.Params.Map()["quietly"] == "quietly")
+		span, _ := info.Params.Span("on")
+		T.Assert(span.Start == 10)
+	})
+}
+
+func TestMatchPolicyEnum(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.Register(p.Command("go", "[dir:enum(north,south)]", "[--speed=speed:enum(walk,run)]").With(lintHandler))
+
+		_, _, err := p.Parse("go NORTH", nil)
+		T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+
+		p.SetPolicy(cparser.LooseMatch)
+		_, info, err := p.Parse("go NORTH --speed Run", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.Value("dir") == "north")
+		T.Assert(info.Params.Value("speed") == "run")
+		T.Assert(info.Params.String("dir") == "NORTH")
+		T.Assert(len(p.Complete("go NO", nil)) == 1)
+	})
+}
+
+func TestMatchPolicyPerFactory(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+		p.SetPolicy(cparser.LooseMatch)
+		factory := p.Command("look").Policy(cparser.ExactMatch)
+		p.Register(factory.With(func(params map[string]string, context interface{}) (commands.Command, error) {
+			return &GoCommand{}, nil
+		}))
+		p.Commands.Register(&GoCommandHandler{})
+
+		_, err := p.Wait("LOOK", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+
+		_, err = p.Wait("look", nil)
+		T.Assert(err == nil)
+	})
+}
+
+func TestMatchPolicyNormalize(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		policy := cparser.MatchPolicy{Normalize: func(word string) string {
+			return strings.Replace(word, "é", "é", -1)
+		}}
+		T.Assert(policy.Match("café", "café"))
+		T.Assert(!cparser.ExactMatch.Match("café", "café"))
+		T.Assert(cparser.LooseMatch.Match("Look!", "look"))
+		T.Assert(cparser.LooseMatch.Match("!", "!"))
+	})
+}
+
+func TestMatchPolicyDecompose(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		precomposed := "caf\u00e9"
+		decomposed := "cafe\u0301"
+		T.Assert(!cparser.ExactMatch.Match(precomposed, decomposed))
+		policy := cparser.MatchPolicy{Decompose: true}
+		T.Assert(policy.Match(precomposed, decomposed))
+		T.Assert(cparser.LooseMatch.Match("CAF\u00c9!", decomposed))
+		T.Assert(cparser.LooseMatch.Match("c\u0327a\u0300", "\u00e7\u00e0"))
+		T.Assert(cparser.LooseMatch.Match("\u0105\u0301", "a\u0301\u0328"))
+		T.Assert(cparser.LooseMatch.Match("\u00e2\u0323", "a\u0323\u0302"))
+		T.Assert(!cparser.LooseMatch.Match("a\u0302\u0301", "a\u0301\u0302"))
+		T.Assert(!cparser.LooseMatch.Match(precomposed, "cafe"))
+
+		p := cparser.New()
+		p.SetPolicy(cparser.LooseMatch)
+		p.Register(p.Command(decomposed, "[item]").With(lintHandler))
+		_, info, err := p.Parse(precomposed+" tea", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("item") == "tea")
+	})
+}
+
+func TestStrictTrailingTokens(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.New()
+
+		params, err := parseWith(p.Command("go", "[dir]"), "go north now please")
+		T.Assert(err == nil)
+		T.Assert(params == nil)
+
+		_, err = parseWith(p.Command().Word("go", true).Token("dir"), "go north now please")
+		T.Assert(err != nil)
+		T.Assert(errors.Is(err, cparser.ErrBadSyntax{}))
+		T.Assert(strings.Contains(err.Error(), "now please"))
+
+		params, err = parseWith(p.Command("go", "[dir]").Strict(false), "go north now please")
+		T.Assert(err == nil)
+		T.Assert(params["dir"] == "north")
+	})
+}
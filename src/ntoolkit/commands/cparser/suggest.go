@@ -0,0 +1,205 @@
+package cparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The most suggestions returned by Suggest.
+const maxSuggestions = 3
+
+// Suggestion is a registered command syntax that is close to a command string.
+type Suggestion struct {
+	// Factory is the factory the suggestion is for.
+	Factory *StandardCommandFactory
+
+	// Syntax is the syntax of the factory, eg. "look [direction]".
+	Syntax string
+
+	// Distance is how far the command string is from the syntax; lower is closer.
+	Distance int
+}
+
+// Suggestions is the inner error of ErrNoHandler when there are registered
+// commands close to the command string that didn't match.
+type Suggestions []Suggestion
+
+func (suggestions Suggestions) Error() string {
+	syntax := make([]string, len(suggestions))
+	for i := range suggestions {
+		syntax[i] = suggestions[i].Syntax
+	}
+	return fmt.Sprintf("Did you mean: %s?", strings.Join(syntax, ", "))
+}
+
+// Suggest returns the registered standard commands closest to a command string,
+// closest first, based on the spelling of the words in each command.
+// Commands with guards that reject the context are never suggested.
+func (p *CommandParser) Suggest(command string, context interface{}) Suggestions {
+	tokens, spans, err := p.tokenize(command)
+	if err != nil {
+		return nil
+	}
+	input := &Input{Raw: command, Tokens: tokens, Context: context, spans: spans}
+	return p.registry().suggest(input)
+}
+
+func (r *registry) suggest(input *Input) Suggestions {
+	tokens := input.tokens()
+	if len(tokens) == 0 {
+		return nil
+	}
+	rtn := make(Suggestions, 0)
+	seen := make(map[string]bool)
+	for i := range r.factory {
+		factory, ok := r.factory[i].(*StandardCommandFactory)
+		if !ok || len(factory.items) == 0 || factory.items[0].Type != standardCommandTypeWord {
+			continue
+		}
+		if allowed, _ := r.allows(i, input.Context); !allowed {
+			continue
+		}
+		distance, ok := factory.distance(r.factoryPolicy(factory), tokens)
+		syntax := factory.String()
+		if ok && !seen[syntax] {
+			seen[syntax] = true
+			rtn = append(rtn, Suggestion{Factory: factory, Syntax: syntax, Distance: distance})
+		}
+	}
+	sort.SliceStable(rtn, func(i, j int) bool {
+		return rtn[i].Distance < rtn[j].Distance
+	})
+	if len(rtn) > maxSuggestions {
+		rtn = rtn[:maxSuggestions]
+	}
+	if len(rtn) == 0 {
+		return nil
+	}
+	return rtn
+}
+
+// distance returns how far the tokens are from this factory, comparing each word of the
+// factory to the token in the same position. If any word is too far from its token,
+// the factory isn't a reasonable suggestion and this returns false.
+func (factory *StandardCommandFactory) distance(policy *MatchPolicy, tokens []Span) (int, bool) {
+	total := 0
+	required := 0
+	for i := range factory.items {
+		item := &factory.items[i]
+		if !item.Optional {
+			required++
+		}
+		if i >= len(tokens) || item.Type != standardCommandTypeWord {
+			continue
+		}
+		words := item.Alternatives
+		if len(words) == 0 {
+			words = []string{item.Name}
+		}
+		best := -1
+		for j := range words {
+			word := policy.Normal(words[j])
+			distance := wordDistance(policy.Normal(tokens[i].Value), word)
+			if distance <= wordThreshold(word) && (best < 0 || distance < best) {
+				best = distance
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		total += best
+	}
+	if len(tokens) < required {
+		total += required - len(tokens)
+	} else if len(tokens) > len(factory.items) {
+		total += len(tokens) - len(factory.items)
+	}
+	return total, true
+}
+
+// wordThreshold is the largest distance a word can be from a token and still be suggested.
+func wordThreshold(word string) int {
+	length := utf8.RuneCountInString(word)
+	if length < 4 {
+		return 2
+	}
+	return 2 * (length / 4)
+}
+
+// wordDistance is the edit distance between two words, where adjacent keys on a keyboard
+// and swapped letters cost 1, other changes cost 2, and being a prefix of the word costs 1.
+func wordDistance(raw string, word string) int {
+	if raw == word {
+		return 0
+	}
+	if utf8.RuneCountInString(raw) >= 2 && strings.HasPrefix(word, raw) {
+		return 1
+	}
+	a := []rune(strings.ToLower(raw))
+	b := []rune(strings.ToLower(word))
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i * 2
+	}
+	for j := range d[0] {
+		d[0][j] = j * 2
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 0
+			if a[i-1] != b[j-1] {
+				cost = 2
+				if keysAdjacent(a[i-1], b[j-1]) {
+					cost = 1
+				}
+			}
+			d[i][j] = minInt(d[i-1][j]+2, d[i][j-1]+2, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// keyboardRows is the layout used to find adjacent keys.
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// keysAdjacent returns true if two keys are next to each other on a keyboard.
+func keysAdjacent(a rune, b rune) bool {
+	rowA, colA := keyPosition(a)
+	rowB, colB := keyPosition(b)
+	if rowA < 0 || rowB < 0 {
+		return false
+	}
+	return absInt(rowA-rowB) <= 1 && absInt(colA-colB) <= 1
+}
+
+func keyPosition(key rune) (int, int) {
+	for row := range keyboardRows {
+		if col := strings.IndexRune(keyboardRows[row], key); col >= 0 {
+			return row, col
+		}
+	}
+	return -1, -1
+}
+
+func minInt(values ...int) int {
+	rtn := values[0]
+	for i := range values {
+		if values[i] < rtn {
+			rtn = values[i]
+		}
+	}
+	return rtn
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func suggestFixture() *cparser.CommandParser {
+	p := cparser.New()
+	p.Register(p.Command("look", "[direction]").With(lintHandler))
+	p.Register(p.Command("lock", "[door]").With(lintHandler))
+	p.Register(p.Command("put", "[item]", "on|onto", "[target]").With(lintHandler))
+	p.Register(p.Command("say", "[message...]").With(lintHandler))
+	return p
+}
+
+func TestSuggestions(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := suggestFixture()
+
+		suggestions := p.Suggest("loko north", nil)
+		T.Assert(len(suggestions) == 1)
+		T.Assert(suggestions[0].Syntax == "look [direction]")
+
+		suggestions = p.Suggest("lok north", nil)
+		T.Assert(len(suggestions) == 2)
+		T.Assert(suggestions[0].Syntax == "look [direction]")
+		T.Assert(suggestions[1].Syntax == "lock [door]")
+
+		suggestions = p.Suggest("put sword inot table", nil)
+		T.Assert(len(suggestions) == 1)
+		T.Assert(suggestions[0].Syntax == "put [item] on|onto [target]")
+
+		suggestions = p.Suggest("sa hello", nil)
+		T.Assert(len(suggestions) == 1)
+		T.Assert(suggestions[0].Syntax == "say [message...]")
+
+		T.Assert(p.Suggest("xyzzy", nil) == nil)
+	})
+}
+
+func TestSuggestionsOnNoHandler(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := suggestFixture()
+
+		_, err := p.Wait("loko north", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+		T.Assert(strings.Contains(err.Error(), "Did you mean: look [direction]"))
+		inner, ok := errors.Inner(err)
+		T.Assert(ok)
+		suggestions, ok := inner.(cparser.Suggestions)
+		T.Assert(ok)
+		T.Assert(suggestions[0].Syntax == "look [direction]")
+
+		_, err = p.Wait("xyzzy", nil)
+		T.Assert(errors.Is(err, cparser.ErrNoHandler{}))
+		_, ok = errors.Inner(err)
+		T.Assert(!ok)
+	})
+}
@@ -0,0 +1,123 @@
+package cparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"ntoolkit/errors"
+)
+
+// SyntaxError is where, and why, a command string failed to match the syntax of a factory.
+// StandardCommandFactory raises it as the inner error of ErrBadSyntax; use FindSyntaxError
+// to get it from the error Execute rejects with.
+type SyntaxError struct {
+	// Input is the command string, if it is known.
+	Input string
+
+	// Factory is the factory that rejected the command, and Syntax its syntax.
+	Factory CommandFactory
+	Syntax  string
+
+	// Token is the index of the offending token, or the number of tokens if the command ended too soon.
+	Token int
+
+	// Start and End are the byte offsets of the offending token in Input; both are len(Input)
+	// if the command ended too soon, or -1 if the token could not be located.
+	Start int
+	End   int
+
+	// Expected is what could have come next; words, eg. "on", or tokens, eg. "[count:int]".
+	// It is empty if nothing more was expected.
+	Expected []string
+
+	// Found is the offending token, or "" if the command ended too soon.
+	Found string
+
+	// Err is the cause, if any; eg. the error converting a typed token.
+	Err error
+
+	Message string
+}
+
+func (err *SyntaxError) Error() string {
+	return err.Message
+}
+
+// Render returns the command string with a caret under the offending token, and the message,
+// on separate lines; or just the message if the offending token could not be located.
+//
+//	put sword onto table
+//	          ^^^^
+//	Invalid syntax for command put [item] on [target]: expected on, found "onto"
+func (err *SyntaxError) Render() string {
+	if err.Start < 0 || err.End < err.Start || err.End > len(err.Input) {
+		return err.Message
+	}
+	column := utf8.RuneCountInString(err.Input[:err.Start])
+	width := utf8.RuneCountInString(err.Input[err.Start:err.End])
+	if width == 0 {
+		width = 1
+	}
+	caret := strings.Repeat(" ", column) + strings.Repeat("^", width)
+	return strings.Join([]string{err.Input, caret, err.Message}, "\n")
+}
+
+// FindSyntaxError returns the SyntaxError that caused err, if there is one.
+func FindSyntaxError(err error) (*SyntaxError, bool) {
+	for err != nil {
+		if syntaxErr, ok := err.(*SyntaxError); ok {
+			return syntaxErr, true
+		}
+		inner, ok := errors.Inner(err)
+		if !ok {
+			return nil, false
+		}
+		err = inner
+	}
+	return nil, false
+}
+
+// syntaxError returns the SyntaxError for a state that failed to match, or nil if the
+// factory should just be skipped.
+func (factory *StandardCommandFactory) syntaxError(state *standardCommandState) *SyntaxError {
+	rtn := &SyntaxError{Input: state.input.Raw, Factory: factory, Syntax: factory.String(), Expected: []string{}}
+	if state.invalidValue != nil {
+		invalid := state.invalidValue
+		rtn.at(state, invalid.token, invalid.span)
+		rtn.Expected = []string{invalid.item.Kind.Name()}
+		rtn.Err = invalid.err
+		rtn.Message = fmt.Sprintf("Invalid value for [%s] in %s: expected %s, found \"%s\"", invalid.item.Name, factory, invalid.item.Kind.Name(), invalid.raw)
+		return rtn
+	}
+	if !state.foundUnique {
+		return nil
+	}
+	if state.trailing >= 0 {
+		trailing := state.cover(state.trailing, len(state.tokens))
+		rtn.at(state, state.trailing, trailing)
+		rtn.Message = fmt.Sprintf("Invalid syntax for command %s, unexpected: %s", factory, trailing.Value)
+		return rtn
+	}
+	rtn.Expected = append(rtn.Expected, state.expected...)
+	if state.furthest >= 0 && state.furthest < len(state.tokens) {
+		rtn.at(state, state.furthest, state.tokens[state.furthest])
+		rtn.Message = fmt.Sprintf("Invalid syntax for command %s: expected %s, found \"%s\"", factory, strings.Join(rtn.Expected, " or "), rtn.Found)
+	} else {
+		rtn.at(state, len(state.tokens), Span{Start: -1, End: -1})
+		rtn.Message = fmt.Sprintf("Invalid syntax for command %s: expected %s, found end of command", factory, strings.Join(rtn.Expected, " or "))
+	}
+	return rtn
+}
+
+// at sets the offending token; a token past the end of the input is placed at the end of the raw input.
+func (err *SyntaxError) at(state *standardCommandState, token int, span Span) {
+	err.Token = state.index(token)
+	err.Found = span.Value
+	err.Start = span.Start
+	err.End = span.End
+	if token >= len(state.tokens) && err.Input != "" {
+		err.Start = len(err.Input)
+		err.End = len(err.Input)
+	}
+}
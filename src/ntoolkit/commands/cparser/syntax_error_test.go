@@ -0,0 +1,87 @@
+package cparser_test
+
+import (
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+)
+
+func syntaxFixture() *cparser.CommandParser {
+	p := cparser.New()
+	p.Register(p.Command().Word("put", true).Token("item").Words("on", "onto").Token("target").With(lintHandler))
+	p.Register(p.Command().Word("give", true).Token("count").As(cparser.TypeInt).Token("item").With(lintHandler))
+	p.Register(p.Command().Word("look", true).Word("quietly").Optional().With(lintHandler))
+	return p
+}
+
+func syntaxErrorFor(T *assert.T, p *cparser.CommandParser, command string) *cparser.SyntaxError {
+	_, _, err := p.Parse(command, nil)
+	T.Assert(errors.Is(err, cparser.ErrCommandFailed{}))
+	syntaxErr, ok := cparser.FindSyntaxError(err)
+	T.Assert(ok)
+	return syntaxErr
+}
+
+func TestSyntaxErrorWrongWord(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		err := syntaxErrorFor(T, syntaxFixture(), "put sword into table")
+		T.Assert(err.Token == 2)
+		T.Assert(err.Start == 10 && err.End == 14)
+		T.Assert(len(err.Expected) == 1 && err.Expected[0] == "on|onto")
+		T.Assert(err.Found == "into")
+		T.Assert(err.Syntax == "put [item] on|onto [target]")
+		T.Assert(err.Message == "Invalid syntax for command put [item] on|onto [target]: expected on|onto, found \"into\"")
+		T.Assert(err.Render() == "put sword into table\n          ^^^^\n"+err.Message)
+	})
+}
+
+func TestSyntaxErrorEndOfCommand(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		err := syntaxErrorFor(T, syntaxFixture(), "put sword")
+		T.Assert(err.Token == 2)
+		T.Assert(err.Start == 9 && err.End == 9)
+		T.Assert(err.Found == "")
+		T.Assert(err.Expected[0] == "on|onto")
+		T.Assert(err.Render() == "put sword\n         ^\n"+err.Message)
+
+		err = syntaxErrorFor(T, syntaxFixture(), "give")
+		T.Assert(err.Expected[0] == "[count:int]")
+	})
+}
+
+func TestSyntaxErrorUnexpected(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		err := syntaxErrorFor(T, syntaxFixture(), "look  quietly now please")
+		T.Assert(err.Token == 2)
+		T.Assert(err.Found == "now please")
+		T.Assert(err.Start == 14 && err.End == 24)
+		T.Assert(len(err.Expected) == 0)
+		T.Assert(err.Message == "Invalid syntax for command look ?quietly, unexpected: now please")
+	})
+}
+
+func TestSyntaxErrorInvalidValue(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		err := syntaxErrorFor(T, syntaxFixture(), "give many apples")
+		T.Assert(err.Token == 1)
+		T.Assert(err.Found == "many")
+		T.Assert(err.Expected[0] == "int")
+		T.Assert(err.Err != nil)
+		T.Assert(err.Render() == "give many apples\n     ^^^^\n"+err.Message)
+	})
+}
+
+func TestSyntaxErrorWithoutInput(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		factory := cparser.New().Command().Word("put", true).Token("item").Word("on").Token("target")
+		_, err := parseWith(factory, "put sword into table")
+		T.Assert(errors.Is(err, cparser.ErrBadSyntax{}))
+		syntaxErr, ok := cparser.FindSyntaxError(err)
+		T.Assert(ok)
+		T.Assert(syntaxErr.Token == 2)
+		T.Assert(syntaxErr.Start == -1)
+		T.Assert(syntaxErr.Render() == syntaxErr.Message)
+	})
+}
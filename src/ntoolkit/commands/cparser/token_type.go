@@ -0,0 +1,137 @@
+package cparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenType converts the raw value of a typed token, like "[count:int]", into the
+// value handed to the command handler.
+type TokenType interface {
+	// Name is the name of the type as it appears in the syntax, eg. "int".
+	Name() string
+
+	// Convert returns the value of raw, or an error if raw is not valid for this type.
+	Convert(raw string) (interface{}, error)
+}
+
+var (
+	// TypeInt accepts integers and yields an int.
+	TypeInt TokenType = &intTokenType{}
+
+	// TypeFloat accepts numbers and yields a float64.
+	TypeFloat TokenType = &floatTokenType{}
+
+	// TypeBool accepts true/false, yes/no and on/off and yields a bool.
+	TypeBool TokenType = &boolTokenType{}
+
+	// TypeDuration accepts values like "10s" or "1h30m" and yields a time.Duration.
+	TypeDuration TokenType = &durationTokenType{}
+)
+
+// TypeEnum accepts one of the given values, and yields it as a string. In a
+// StandardCommandFactory values are compared under its MatchPolicy, and the value
+// yielded is the one from the list; under LooseMatch "NORTH" is "north".
+func TypeEnum(values ...string) TokenType {
+	return &enumTokenType{values: values}
+}
+
+// tokenType returns the built in type for a spec like "int" or "enum(north,south)".
+func tokenType(spec string) (TokenType, bool) {
+	switch spec {
+	case TypeInt.Name():
+		return TypeInt, true
+	case TypeFloat.Name():
+		return TypeFloat, true
+	case TypeBool.Name():
+		return TypeBool, true
+	case TypeDuration.Name():
+		return TypeDuration, true
+	}
+	if strings.HasPrefix(spec, "enum(") && strings.HasSuffix(spec, ")") {
+		values := strings.Split(spec[5:len(spec)-1], ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		return TypeEnum(values...), true
+	}
+	return nil, false
+}
+
+type intTokenType struct{}
+
+func (t *intTokenType) Name() string {
+	return "int"
+}
+
+func (t *intTokenType) Convert(raw string) (interface{}, error) {
+	return strconv.Atoi(raw)
+}
+
+type floatTokenType struct{}
+
+func (t *floatTokenType) Name() string {
+	return "float"
+}
+
+func (t *floatTokenType) Convert(raw string) (interface{}, error) {
+	return strconv.ParseFloat(raw, 64)
+}
+
+type boolTokenType struct{}
+
+func (t *boolTokenType) Name() string {
+	return "bool"
+}
+
+func (t *boolTokenType) Convert(raw string) (interface{}, error) {
+	switch strings.ToLower(raw) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(raw)
+}
+
+type durationTokenType struct{}
+
+func (t *durationTokenType) Name() string {
+	return "duration"
+}
+
+func (t *durationTokenType) Convert(raw string) (interface{}, error) {
+	return time.ParseDuration(raw)
+}
+
+type enumTokenType struct {
+	values []string
+}
+
+func (t *enumTokenType) Name() string {
+	return fmt.Sprintf("enum(%s)", strings.Join(t.values, ","))
+}
+
+func (t *enumTokenType) Convert(raw string) (interface{}, error) {
+	return t.match(nil, raw)
+}
+
+// match returns the value that raw is under the policy.
+func (t *enumTokenType) match(policy *MatchPolicy, raw string) (interface{}, error) {
+	for i := range t.values {
+		if policy.Match(raw, t.values[i]) {
+			return t.values[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%q is not one of %s", raw, strings.Join(t.values, ", "))
+}
+
+// convert returns the value of raw for a type, comparing enum values under the policy.
+func convert(kind TokenType, policy *MatchPolicy, raw string) (interface{}, error) {
+	if enum, ok := kind.(*enumTokenType); ok {
+		return enum.match(policy, raw)
+	}
+	return kind.Convert(raw)
+}
@@ -0,0 +1,157 @@
+package cparser
+
+import (
+	"strings"
+	"sync"
+
+	"ntoolkit/errors"
+	"ntoolkit/parser"
+	"ntoolkit/parser/tools"
+)
+
+// Tokenizer splits a command string into tokens. It must be safe for concurrent use.
+type Tokenizer interface {
+	Tokenize(command string) (*parser.Tokens, error)
+}
+
+// SpanTokenizer is a Tokenizer that also returns where each token is in the command string.
+// Implement it when tokens can't be found by searching the command string for their value;
+// eg. if they can contain escapes.
+type SpanTokenizer interface {
+	Tokenizer
+	TokenizeSpans(command string) (*parser.Tokens, []Span, error)
+}
+
+// BlockTokenizer splits command strings with a tools.BlockParser; words are separated by
+// spaces, and "quoted blocks" are a single token. It is the default Tokenizer.
+type BlockTokenizer struct {
+	// Block parsers are not safe for concurrent use, so each call takes its own.
+	blockParsers sync.Pool
+}
+
+// NewBlockTokenizer returns a new BlockTokenizer.
+func NewBlockTokenizer() *BlockTokenizer {
+	rtn := &BlockTokenizer{}
+	rtn.blockParsers.New = func() interface{} {
+		return tools.NewBlockParser()
+	}
+	return rtn
+}
+
+// Tokenize converts a command string into a token stream.
+func (tokenizer *BlockTokenizer) Tokenize(command string) (*parser.Tokens, error) {
+	blockParser := tokenizer.blockParsers.Get().(*tools.BlockParser)
+	blockParser.Parse(command)
+	tokens, err := blockParser.Finished()
+	if err == nil {
+		tokenizer.blockParsers.Put(blockParser)
+	}
+	return tokens, err
+}
+
+// ShellTokenizer splits command strings like a POSIX shell does. Words are separated by
+// whitespace; 'single quotes' keep everything inside as it is, "double quotes" allow \" \\ \$
+// and \` escapes, a backslash outside quotes escapes the next character, and a backslash at
+// the end of a line joins it to the next. Quoted and unquoted parts next to each other are
+// the same token; a'b c'd is "ab cd".
+type ShellTokenizer struct {
+}
+
+// NewShellTokenizer returns a new ShellTokenizer.
+func NewShellTokenizer() *ShellTokenizer {
+	return &ShellTokenizer{}
+}
+
+// Tokenize converts a command string into a token stream.
+func (tokenizer *ShellTokenizer) Tokenize(command string) (*parser.Tokens, error) {
+	tokens, _, err := tokenizer.TokenizeSpans(command)
+	return tokens, err
+}
+
+// TokenizeSpans converts a command string into a token stream, and the span of each token
+// in the command string, including any quotes and escapes.
+func (tokenizer *ShellTokenizer) TokenizeSpans(command string) (*parser.Tokens, []Span, error) {
+	spans := make([]Span, 0)
+	value := &strings.Builder{}
+	start := -1
+	finish := func(end int) {
+		if start >= 0 {
+			spans = append(spans, Span{Value: value.String(), Start: start, End: end})
+			value.Reset()
+			start = -1
+		}
+	}
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\' && i+1 < len(command) && command[i+1] == '\n':
+			i++
+		case c == '\\':
+			if i+1 == len(command) {
+				return nil, nil, errors.Fail(ErrBadSyntax{}, nil, "Unfinished escape at the end of the command")
+			}
+			if start < 0 {
+				start = i
+			}
+			i++
+			value.WriteByte(command[i])
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			finish(i)
+		case c == '\'':
+			if start < 0 {
+				start = i
+			}
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, nil, errors.Fail(ErrBadSyntax{}, nil, "Unfinished 'quote'")
+			}
+			value.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			if start < 0 {
+				start = i
+			}
+			closed := false
+			for i++; i < len(command); i++ {
+				c = command[i]
+				if c == '"' {
+					closed = true
+					break
+				}
+				if c == '\\' && i+1 < len(command) && strings.IndexByte("$`\"\\\n", command[i+1]) >= 0 {
+					i++
+					if command[i] != '\n' {
+						value.WriteByte(command[i])
+					}
+					continue
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return nil, nil, errors.Fail(ErrBadSyntax{}, nil, "Unfinished \"quote\"")
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+			value.WriteByte(c)
+		}
+	}
+	finish(len(command))
+	return newTokens(spans), spans, nil
+}
+
+// newTokens returns a token stream of block tokens, one for each span.
+func newTokens(spans []Span) *parser.Tokens {
+	rtn := &parser.Tokens{}
+	for i := range spans {
+		token := &parser.Token{Type: tools.TokenTypeBlock, Raw: []string{spans[i].Value}, Prev: rtn.Back}
+		if rtn.Back != nil {
+			rtn.Back.Next = token
+		} else {
+			rtn.Front = token
+		}
+		rtn.Back = token
+	}
+	return rtn
+}
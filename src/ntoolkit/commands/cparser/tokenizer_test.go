@@ -0,0 +1,151 @@
+package cparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"ntoolkit/assert"
+	"ntoolkit/commands"
+	"ntoolkit/commands/cparser"
+	"ntoolkit/errors"
+	"ntoolkit/parser"
+	"ntoolkit/parser/tools"
+)
+
+// tokenValues returns the value of each token, separated by '|'.
+func tokenValues(tokens *parser.Tokens) string {
+	values := make([]string, 0)
+	for marker := tokens.Front; marker != nil; marker = marker.Next {
+		values = append(values, marker.CollectRaw(" "))
+	}
+	return strings.Join(values, "|")
+}
+
+// CommaTokenizer splits command strings on commas.
+type CommaTokenizer struct {
+}
+
+func (tokenizer *CommaTokenizer) Tokenize(command string) (*parser.Tokens, error) {
+	rtn := &parser.Tokens{}
+	for _, value := range strings.Split(command, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		token := &parser.Token{Type: tools.TokenTypeBlock, Raw: []string{value}, Prev: rtn.Back}
+		if rtn.Back != nil {
+			rtn.Back.Next = token
+		} else {
+			rtn.Front = token
+		}
+		rtn.Back = token
+	}
+	return rtn, nil
+}
+
+func TestShellTokenizer(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		tokenizer := cparser.NewShellTokenizer()
+		tokenize := func(command string) string {
+			tokens, err := tokenizer.Tokenize(command)
+			T.Assert(err == nil)
+			return tokenValues(tokens)
+		}
+		T.Assert(tokenize("go  north") == "go|north")
+		T.Assert(tokenize("say 'hello  world'") == "say|hello  world")
+		T.Assert(tokenize("say 'it\\'s") == "say|it\\s")
+		T.Assert(tokenize("say \"a \\\"b\\\" \\n\"") == "say|a \"b\" \\n")
+		T.Assert(tokenize("take rusty\\ sword") == "take|rusty sword")
+		T.Assert(tokenize("take a'b c'd") == "take|ab cd")
+		T.Assert(tokenize("go \\\nnorth") == "go|north")
+		T.Assert(tokenize("say '' \"\"") == "say||")
+		T.Assert(tokenize("") == "")
+	})
+}
+
+func TestShellTokenizerSpans(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		command := "put 'rusty sword' on\\ top"
+		_, spans, err := cparser.NewShellTokenizer().TokenizeSpans(command)
+		T.Assert(err == nil)
+		T.Assert(len(spans) == 3)
+		T.Assert(spans[1].Value == "rusty sword")
+		T.Assert(command[spans[1].Start:spans[1].End] == "'rusty sword'")
+		T.Assert(spans[2].Value == "on top")
+		T.Assert(command[spans[2].Start:spans[2].End] == "on\\ top")
+	})
+}
+
+func TestShellTokenizerUnfinished(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		tokenizer := cparser.NewShellTokenizer()
+		for _, command := range []string{"say 'hello", "say \"hello", "say hello\\"} {
+			_, err := tokenizer.Tokenize(command)
+			T.Assert(errors.Is(err, cparser.ErrBadSyntax{}))
+		}
+	})
+}
+
+func TestBlockTokenizer(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		tokens, err := cparser.NewBlockTokenizer().Tokenize("say \"hello world\" twice")
+		T.Assert(err == nil)
+		T.Assert(tokenValues(tokens) == "say|hello world|twice")
+	})
+}
+
+func TestWithTokenizer(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.NewWithOptions(cparser.WithTokenizer(cparser.NewShellTokenizer()))
+		p.Register(p.Command("put", "[item]", "on", "[target]").With(lintHandler))
+
+		_, info, err := p.Parse("put rusty\\ sword on 'the table'", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("item") == "rusty sword")
+		T.Assert(info.Params.String("target") == "the table")
+		span, _ := info.Params.Span("target")
+		T.Assert(span.Start == 20)
+
+		_, _, err = p.Parse("put 'rusty sword on table", nil)
+		T.Assert(errors.Is(err, cparser.ErrBadSyntax{}))
+	})
+}
+
+func TestWithTokenizerSequence(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.NewWithOptions(cparser.WithTokenizer(cparser.NewShellTokenizer()))
+		parts, err := p.Sequencer().Split("say a\\;b 'then' ; look")
+		T.Assert(err == nil)
+		T.Assert(len(parts) == 2)
+		T.Assert(parts[0] == "say a\\;b 'then'")
+	})
+}
+
+func TestWithCustomTokenizer(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.NewWithOptions(cparser.WithTokenizer(&CommaTokenizer{}))
+		p.Register(p.Command("put", "[item]", "on", "[target]").With(lintHandler))
+		_, info, err := p.Parse("put, rusty sword, on, table", nil)
+		T.Assert(err == nil)
+		T.Assert(info.Params.String("item") == "rusty sword")
+		span, _ := info.Params.Span("item")
+		T.Assert(span.Start == 5)
+	})
+}
+
+func TestWithTokenizerCompleteUnfinishedQuote(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		p := cparser.NewWithOptions(cparser.WithTokenizer(cparser.NewShellTokenizer()))
+		p.Register(p.Command("say", "[message...]", "[--loud]").With(lintHandler))
+		T.Assert(len(p.Complete("say \"hello ", nil)) == 0)
+	})
+}
+
+func TestWithCommands(T *testing.T) {
+	assert.Test(T, func(T *assert.T) {
+		cmd := commands.New()
+		T.Assert(cparser.NewWithOptions(cparser.WithCommands(cmd)).Commands == cmd)
+		T.Assert(cparser.New(cmd).Commands == cmd)
+		T.Assert(cparser.New().Commands != nil)
+	})
+}